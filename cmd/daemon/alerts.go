@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/alert"
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// checkAlerts runs the alert rules over a newly fetched observation and
+// returns the ones to deliver, recording them for the cooldown. Callers must
+// hold the cache lock. Failures are logged; alerts never fail an update.
+func checkAlerts(cfg config.AlertCfg, icao string, prev, cur types.Observation) []alert.Alert {
+	// nothing to compare against on a station's first fetch
+	if len(cfg.Rules) == 0 || prev.Epoch == 0 || cur.Epoch <= prev.Epoch {
+		return nil
+	}
+
+	var history []types.Observation
+	if window := alert.Window(cfg.Rules); window > 0 {
+		// prev is checked as well as cur, so the history has to reach a
+		// full window back from the older of the two
+		var err error
+		history, err = cache.History(icao, time.Unix(prev.Epoch, 0).Add(-window))
+		if err != nil {
+			slog.Warn("Alert history read failed", "error", err)
+		}
+	}
+
+	alerts := alert.Evaluate(cfg.Rules, icao, prev, cur, history)
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	fired, err := cache.AlertState()
+	if err != nil {
+		slog.Warn("Alert state read failed", "error", err)
+		return nil
+	}
+	cooldown := time.Duration(cfg.CooldownMinutes) * time.Minute
+	alerts = alert.Throttle(alerts, fired, cooldown, time.Now())
+	if len(alerts) == 0 {
+		return nil
+	}
+	if err := cache.SetAlertState(fired); err != nil {
+		slog.Warn("Alert state write failed", "error", err)
+	}
+	return alerts
+}
+
+// deliverAlerts hands alerts to the configured notifiers. Notifiers run
+// external programs, so call it without the cache lock held.
+func deliverAlerts(cfg config.AlertCfg, alerts []alert.Alert) {
+	if len(alerts) == 0 {
+		return
+	}
+	notifiers, err := alert.Notifiers(cfg.Notifiers)
+	if err != nil {
+		slog.Error("Alerts", "error", err)
+		return
+	}
+	for _, a := range alerts {
+		slog.Info("Alert", "icao", a.ICAO, "rule", a.Rule)
+		for _, n := range notifiers {
+			if err := n.Notify(a); err != nil {
+				slog.Warn("Alert delivery failed", "rule", a.Rule, "error", err)
+			}
+		}
+	}
+}
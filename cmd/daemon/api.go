@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+const sseKeepalive = 30 * time.Second
+
+// apiEvent is the payload of each SSE "update" event
+type apiEvent struct {
+	Products []string      `json:"products"` // keys whose data changed
+	Airport  types.Airport `json:"airport"`
+}
+
+// eventHub fans product updates out to /events subscribers. A subscriber that
+// falls behind misses events rather than stalling the run loop.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: make(map[chan []byte]struct{})}
+}
+
+func (h *eventHub) subscribe() chan []byte {
+	ch := make(chan []byte, 8)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan []byte) {
+	h.mu.Lock()
+	delete(h.subs, ch)
+	h.mu.Unlock()
+}
+
+func (h *eventHub) publish(wx types.Airport, changed []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.subs) == 0 {
+		return
+	}
+	data, err := json.Marshal(apiEvent{Products: changed, Airport: wx})
+	if err != nil {
+		slog.Warn("Event encode failed", "error", err)
+		return
+	}
+	for ch := range h.subs {
+		select {
+		case ch <- data:
+		default:
+			slog.Debug("Dropping event for slow subscriber")
+		}
+	}
+}
+
+// changedProducts lists the products fetched since before, or every product
+// present when the station itself changed
+func changedProducts(before, after types.Airport) []string {
+	var changed []string
+	for _, p := range products {
+		state, ok := after.Products[p]
+		if !ok {
+			continue
+		}
+		if after.ICAO != before.ICAO || state.FetchedAt != before.Products[p].FetchedAt {
+			changed = append(changed, p)
+		}
+	}
+	return changed
+}
+
+// productData picks one product's decoded data out of an entry
+func productData(wx types.Airport, product string) (any, bool) {
+	switch product {
+	case types.ProductMETAR:
+		return struct {
+			METAR      types.METAR       `json:"metar"`
+			Substitute *types.Substitute `json:"substitute,omitempty"`
+		}{wx.METAR, wx.METARSub}, true
+	case types.ProductTAF:
+		return struct {
+			Raw        string            `json:"raw"`
+			Substitute *types.Substitute `json:"substitute,omitempty"`
+		}{wx.RawTAF, wx.TAFSub}, true
+	case types.ProductAFD:
+		return wx.AFD, true
+	case types.ProductStation:
+		return struct {
+			ICAO      string     `json:"icao"`
+			Name      string     `json:"name"`
+			CWA       string     `json:"cwa"`
+			Lat       float64    `json:"lat"`
+			Lon       float64    `json:"lon"`
+			Elevation types.Feet `json:"elevation"`
+		}{wx.ICAO, wx.Name, wx.CWA, wx.Lat, wx.Lon, wx.Elevation}, true
+	case types.ProductPIREP:
+		return wx.PIREPs, true
+	case types.ProductHazards:
+		return wx.Hazards, true
+	case types.ProductAloft:
+		return wx.WindsAloft, true
+	}
+	return nil, false
+}
+
+// serveAPI starts the read-only HTTP API on addr:
+//
+//	GET /api/airport          the active station's full entry
+//	GET /api/products         per-product refresh state
+//	GET /api/products/{name}  one product's data
+//	GET /events               SSE stream, one "update" event per change
+//
+// Browsers may only read it from the origins listed in the config, and only
+// by a Host of localhost or the listen address, so a DNS-rebinding page can't
+// reach it under its own name.
+func serveAPI(ctx context.Context, cfg config.APICfg, hub *eventHub) (func(), error) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/airport", func(w http.ResponseWriter, r *http.Request) {
+		withActive(w, func(wx types.Airport) any { return wx })
+	})
+	mux.HandleFunc("GET /api/products", func(w http.ResponseWriter, r *http.Request) {
+		withActive(w, func(wx types.Airport) any { return wx.Products })
+	})
+	mux.HandleFunc("GET /api/products/{name}", func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if !slices.Contains(products, name) {
+			http.Error(w, fmt.Sprintf("unknown product %q", name), http.StatusNotFound)
+			return
+		}
+		withActive(w, func(wx types.Airport) any {
+			data, _ := productData(wx, name)
+			return data
+		})
+	})
+	mux.HandleFunc("GET /events", func(w http.ResponseWriter, r *http.Request) {
+		streamEvents(w, r, hub)
+	})
+
+	ln, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("api listen: %w", err)
+	}
+	srv := &http.Server{
+		Handler:           allowHosts(cfg.Listen, allowOrigins(cfg.AllowOrigins, mux)),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("API server", "error", err)
+		}
+	}()
+	slog.Info("API listening", "addr", ln.Addr().String())
+
+	return func() {
+		shutdown, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}, nil
+}
+
+// allowHosts rejects requests whose Host header names anything other than the
+// loopback or the host the API listens on
+func allowHosts(listen string, next http.Handler) http.Handler {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if host, _, err := net.SplitHostPort(listen); err == nil && host != "" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsUnspecified() {
+			hosts = append(hosts, strings.ToLower(host))
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.Trim(host, "[]"))
+		if !slices.Contains(hosts, host) {
+			http.Error(w, "host not allowed", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowOrigins lets web pages from the listed origins read responses; any
+// other page gets no CORS headers, so the browser keeps the data from it
+func allowOrigins(origins []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && slices.Contains(origins, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withActive reads the active station and writes pick's result as JSON
+func withActive(w http.ResponseWriter, pick func(types.Airport) any) {
+	wx, err := cache.Read()
+	if err != nil {
+		http.Error(w, "no cached data: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, pick(wx))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Debug("API write failed", "error", err)
+	}
+}
+
+// streamEvents sends the current entry straight away, then every update
+func streamEvents(w http.ResponseWriter, r *http.Request, hub *eventHub) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := hub.subscribe()
+	defer hub.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if wx, err := cache.Read(); err == nil {
+		if data, err := json.Marshal(apiEvent{Products: changedProducts(types.Airport{}, wx), Airport: wx}); err == nil {
+			fmt.Fprintf(w, "event: update\ndata: %s\n\n", data)
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(sseKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case data := <-ch:
+			fmt.Fprintf(w, "event: update\ndata: %s\n\n", data)
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		flusher.Flush()
+	}
+}
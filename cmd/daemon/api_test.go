@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowOrigins(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		allowed []string
+		origin  string
+		want    string
+	}{
+		{nil, "", ""},
+		{nil, "https://evil.example", ""},
+		{[]string{"http://localhost:3000"}, "https://evil.example", ""},
+		{[]string{"http://localhost:3000"}, "http://localhost:3000", "http://localhost:3000"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/airport", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		rec := httptest.NewRecorder()
+		allowOrigins(tt.allowed, ok).ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("allowed %v, origin %q: header = %q, want %q", tt.allowed, tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestAllowHosts(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		listen, host string
+		want         int
+	}{
+		{"127.0.0.1:8765", "127.0.0.1:8765", http.StatusOK},
+		{"127.0.0.1:8765", "localhost:8765", http.StatusOK},
+		{"127.0.0.1:8765", "LOCALHOST", http.StatusOK},
+		{"127.0.0.1:8765", "[::1]:8765", http.StatusOK},
+		{"127.0.0.1:8765", "evil.example:8765", http.StatusForbidden},
+		{"127.0.0.1:8765", "", http.StatusForbidden},
+		{"192.168.1.5:8765", "192.168.1.5:8765", http.StatusOK},
+		{"pi.lan:8765", "pi.lan:8765", http.StatusOK},
+		{"pi.lan:8765", "evil.example", http.StatusForbidden},
+		// a wildcard bind doesn't make every name acceptable
+		{"0.0.0.0:8765", "evil.example:8765", http.StatusForbidden},
+		{":8765", "localhost:8765", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/airport", nil)
+		req.Host = tt.host
+		rec := httptest.NewRecorder()
+		allowHosts(tt.listen, ok).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("listen %s, Host %q: status %d, want %d", tt.listen, tt.host, rec.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+const (
+	ctlSocket = "control.sock"
+
+	// a switch or refresh can sit through several fetch retries
+	ctlTimeout = 2 * time.Minute
+)
+
+// ctlRequest is one line of JSON sent to the control socket
+type ctlRequest struct {
+	Cmd  string `json:"cmd"` // switch, refresh-now, status, get-current, list-stations, reload-config
+	ICAO string `json:"icao,omitempty"`
+}
+
+type ctlResponse struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Data  any    `json:"data,omitempty"`
+}
+
+type ctlStatus struct {
+	Airport    string                        `json:"airport"`
+	PID        int                           `json:"pid"`
+	Started    time.Time                     `json:"started"`
+	NextUpdate time.Time                     `json:"nextUpdate"`
+	LastUpdate int64                         `json:"lastUpdate"`
+	LastError  string                        `json:"lastError,omitempty"`
+	Products   map[string]types.ProductState `json:"products"`
+}
+
+// pendingCtl carries a request to the run loop and its answer back
+type pendingCtl struct {
+	ctlRequest
+	reply chan ctlResponse
+}
+
+func ctlPath() string {
+	return filepath.Join(runtimeDir(), ctlSocket)
+}
+
+// serveControl listens on the control socket and forwards each request to the
+// returned channel, which the run loop answers
+func serveControl(ctx context.Context) (<-chan pendingCtl, func(), error) {
+	path := ctlPath()
+	os.Remove(path) // stale socket from a crash; the pidfile lock says we own it
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, nil, fmt.Errorf("control socket: %w", err)
+	}
+
+	requests := make(chan pendingCtl)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					slog.Warn("Control accept failed", "error", err)
+				}
+				return
+			}
+			go handleConn(ctx, conn, requests)
+		}
+	}()
+
+	return requests, func() {
+		ln.Close()
+		os.Remove(path)
+	}, nil
+}
+
+func handleConn(ctx context.Context, conn net.Conn, requests chan<- pendingCtl) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ctlTimeout))
+
+	var req ctlRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		json.NewEncoder(conn).Encode(ctlResponse{Error: "bad request: " + err.Error()})
+		return
+	}
+	slog.Debug("Control request", "cmd", req.Cmd)
+
+	pending := pendingCtl{ctlRequest: req, reply: make(chan ctlResponse, 1)}
+	select {
+	case requests <- pending:
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case resp := <-pending.reply:
+		json.NewEncoder(conn).Encode(resp)
+	case <-ctx.Done():
+	}
+}
+
+// handle answers a control request from inside the run loop
+func (d *daemon) handle(ctx context.Context, req ctlRequest) ctlResponse {
+	switch req.Cmd {
+	case "switch":
+		if err := d.switchTo(ctx, req.ICAO); err != nil {
+			return ctlResponse{Error: err.Error()}
+		}
+		return d.current()
+	case "refresh-now", "refresh": // refresh is the older name
+		*d.flags.Update = true
+		d.cycle(ctx)
+		if d.lastErr != nil {
+			return ctlResponse{Error: d.lastErr.Error()}
+		}
+		return d.current()
+	case "status":
+		return ctlResponse{OK: true, Data: d.status()}
+	case "get-current":
+		return d.current()
+	case "list-stations":
+		stations, err := cache.Stations()
+		if err != nil {
+			return ctlResponse{Error: err.Error()}
+		}
+		return ctlResponse{OK: true, Data: stations}
+	case "reload-config":
+		if err := d.reload(ctx); err != nil {
+			return ctlResponse{Error: err.Error()}
+		}
+		return ctlResponse{OK: true}
+	default:
+		return ctlResponse{Error: fmt.Sprintf("unknown command %q", req.Cmd)}
+	}
+}
+
+func (d *daemon) current() ctlResponse {
+	wx, err := cache.ReadStation(*d.flags.Airport)
+	if err != nil {
+		return ctlResponse{Error: err.Error()}
+	}
+	return ctlResponse{OK: true, Data: wx}
+}
+
+func (d *daemon) status() ctlStatus {
+	s := ctlStatus{
+		Airport:    *d.flags.Airport,
+		PID:        os.Getpid(),
+		Started:    d.started,
+		NextUpdate: d.nextRun,
+	}
+	if d.lastErr != nil {
+		s.LastError = d.lastErr.Error()
+	}
+	if wx, err := cache.ReadStation(*d.flags.Airport); err == nil {
+		s.LastUpdate = wx.LastUpdateEpoch
+		s.Products = wx.Products
+	}
+	return s
+}
+
+// ctlSend makes one request to a running daemon
+func ctlSend(req ctlRequest) (ctlResponse, error) {
+	conn, err := net.DialTimeout("unix", ctlPath(), time.Second)
+	if err != nil {
+		return ctlResponse{}, fmt.Errorf("daemon not reachable: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ctlTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return ctlResponse{}, fmt.Errorf("send failed: %w", err)
+	}
+	var resp ctlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return ctlResponse{}, fmt.Errorf("read failed: %w", err)
+	}
+	return resp, nil
+}
+
+// runCtl is the `ctl` client: pilot-bar-daemon ctl <command> [ICAO]
+func runCtl(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: pilot-bar-daemon ctl <switch ICAO|refresh-now|status|get-current|list-stations|reload-config>")
+	}
+	req := ctlRequest{Cmd: args[0]}
+	if req.Cmd == "switch" {
+		if len(args) < 2 {
+			return fmt.Errorf("usage: pilot-bar-daemon ctl switch <ICAO>")
+		}
+		req.ICAO = strings.ToUpper(args[1])
+	}
+
+	resp, err := ctlSend(req)
+	if err != nil {
+		return err
+	}
+	if !resp.OK {
+		return errors.New(resp.Error)
+	}
+	if resp.Data != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp.Data)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+func TestHandleRefresh(t *testing.T) {
+	flags, cfg := testUpdate(t, config.ModuleCfg{METAR: true})
+	var hits atomic.Int32
+	src := fakeSource(t, map[string]http.HandlerFunc{
+		"/metar": func(w http.ResponseWriter, r *http.Request) { hits.Add(1); metarHandler(w, r) },
+	})
+	d := &daemon{src: src, flags: flags, cfg: cfg, events: newEventHub()}
+	ctx := context.Background()
+
+	// both names force a fetch, even with the METAR still fresh
+	for i, cmd := range []string{"refresh-now", "refresh"} {
+		resp := d.handle(ctx, ctlRequest{Cmd: cmd})
+		if !resp.OK {
+			t.Fatalf("%s: %s", cmd, resp.Error)
+		}
+		if wx, ok := resp.Data.(types.Airport); !ok || wx.METAR.RawOb != testMETAR {
+			t.Errorf("%s answered %+v, want the current entry", cmd, resp.Data)
+		}
+		if n := hits.Load(); n != int32(i+1) {
+			t.Errorf("%s: %d METAR fetches, want %d", cmd, n, i+1)
+		}
+		if *d.flags.Update {
+			t.Errorf("%s left the force flag set", cmd)
+		}
+	}
+
+	resp := d.handle(ctx, ctlRequest{Cmd: "refresh-later"})
+	if resp.OK || !strings.Contains(resp.Error, "unknown command") {
+		t.Errorf("unknown command answered %+v", resp)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/translate"
+	"github.com/house-holder/pilot-bar/pkg/pilotbar"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// decoded is one raw report after detection and decoding
+type decoded struct {
+	pilotbar.Report
+	Error string `json:"error,omitempty"`
+}
+
+var tafContinues = regexp.MustCompile(`^(FM\d{6}|TEMPO|BECMG|PROB\d{2})\b`)
+
+// decodeReports decodes raw METAR, SPECI, TAF or PIREP text given as args, or
+// read from stdin when there are none, and prints it as a table, json or
+// plain English
+func decodeReports(args []string, format string) error {
+	var reports []string
+	if len(args) > 0 {
+		for _, a := range args {
+			reports = append(reports, splitReports(strings.NewReader(a))...)
+		}
+	} else {
+		reports = splitReports(os.Stdin)
+	}
+	if len(reports) == 0 {
+		return errors.New("no reports to decode")
+	}
+
+	switch format {
+	case "table", "json", "plain", "":
+	default:
+		return fmt.Errorf("unknown format %q (table, json, plain)", format)
+	}
+
+	now := time.Now()
+	results := make([]decoded, 0, len(reports))
+	failed := 0
+	for _, raw := range reports {
+		d := decodeReport(raw, now)
+		if d.Error != "" {
+			failed++
+		}
+		results = append(results, d)
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	default:
+		for i, d := range results {
+			if i > 0 {
+				fmt.Println()
+			}
+			if d.Error != "" {
+				fmt.Printf("%s: %s\n", d.Kind, d.Error)
+				continue
+			}
+			if format == "plain" {
+				fmt.Println(decodePlain(d))
+			} else if err := decodeTable(d); err != nil {
+				return err
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d reports failed to decode", failed, len(results))
+	}
+	return nil
+}
+
+// splitReports breaks text into reports, one per line, joining indented or
+// FM/TEMPO/BECMG/PROB lines onto the TAF above them. Blank lines and a
+// trailing "=" also end a report.
+func splitReports(r io.Reader) []string {
+	var reports []string
+	var cur []string
+	end := func() {
+		if len(cur) > 0 {
+			reports = append(reports, strings.Join(cur, " "))
+			cur = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			end()
+			continue
+		}
+		continued := len(cur) > 0 && (line[0] == ' ' || line[0] == '\t' || tafContinues.MatchString(trimmed))
+		if !continued {
+			end()
+		}
+		cur = append(cur, trimmed)
+		if strings.HasSuffix(trimmed, "=") {
+			end()
+		}
+	}
+	end()
+	return reports
+}
+
+func decodeReport(raw string, now time.Time) decoded {
+	r, err := pilotbar.Decode(raw, now)
+	d := decoded{Report: r}
+	if err != nil {
+		if d.Kind == "" {
+			d.Kind = "UNKNOWN"
+		}
+		d.Error = fmt.Sprintf("%v: %q", err, raw)
+	}
+	return d
+}
+
+func decodePlain(d decoded) string {
+	switch {
+	case d.METAR != nil:
+		return d.Station + ": " + translate.METAR(*d.METAR)
+	case d.TAF != nil:
+		return translate.TAF(*d.TAF)
+	case d.PIREP != nil:
+		return translate.PIREP(*d.PIREP)
+	}
+	return ""
+}
+
+func decodeTable(d decoded) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	row := func(k, v string) {
+		if v != "" {
+			fmt.Fprintf(w, "%s\t%s\n", k, v)
+		}
+	}
+
+	switch {
+	case d.METAR != nil:
+		m := d.METAR
+		row("TYPE", m.Type)
+		row("STATION", d.Station)
+		row("TIME", time.Unix(m.Reported.Epoch, 0).UTC().Format("02 1504Z"))
+		row("WIND", decodeWind(&m.Wind))
+		row("VIS", decodeVis(m.Visibility))
+		row("WX", m.WxString)
+		row("CLOUDS", decodeClouds(m.Clouds))
+		row("TEMP/DEW", fmt.Sprintf("%.1f/%.1f", m.Temp.AmbientExact, m.Temp.DewpointExact))
+		if m.Altimeter > 0 {
+			row("ALTIM", fmt.Sprintf("%.2f", float64(m.Altimeter)))
+		}
+		row("CAT", m.FltCat)
+		row("REMARKS", strings.Join(m.Remarks.Readable, "; "))
+	case d.TAF != nil:
+		t := d.TAF
+		row("TYPE", "TAF")
+		row("STATION", t.Station)
+		if t.Issued > 0 {
+			row("ISSUED", time.Unix(t.Issued, 0).UTC().Format("02 1504Z"))
+		}
+		row("VALID", time.Unix(t.ValidFrom, 0).UTC().Format("02 15Z")+" - "+time.Unix(t.ValidTo, 0).UTC().Format("02 15Z"))
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "CHANGE\tFROM\tTO\tWIND\tVIS\tWX\tCLOUDS")
+		for _, g := range t.Groups {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				g.Change,
+				time.Unix(g.From, 0).UTC().Format("02 15Z"),
+				time.Unix(g.To, 0).UTC().Format("02 15Z"),
+				orDash(decodeWind(g.Wind)),
+				orDash(decodeVis(g.Visibility)),
+				orDash(strings.TrimSpace(g.WxString+" "+g.WindShear)),
+				orDash(decodeClouds(g.Clouds)),
+			)
+		}
+	case d.PIREP != nil:
+		p := d.PIREP
+		kind := "UA"
+		if p.Urgent {
+			kind = "UUA"
+		}
+		row("TYPE", kind)
+		row("STATION", d.Station)
+		row("LOCATION", p.Location)
+		if p.Time != "" {
+			row("TIME", p.Time+"Z")
+		}
+		if p.Altitude != nil {
+			row("ALTITUDE", fmt.Sprintf("%d ft", *p.Altitude))
+		}
+		row("AIRCRAFT", p.AircraftType)
+		row("SKY", p.Sky)
+		row("WX", p.Weather)
+		if p.Temp != nil {
+			row("TEMP", fmt.Sprintf("%d", *p.Temp))
+		}
+		row("WIND", decodeWind(p.Wind))
+		row("TURB", decodeConditions(p.Turbulence))
+		row("ICING", decodeConditions(p.Icing))
+		row("REMARKS", p.Remarks)
+	}
+	return w.Flush()
+}
+
+func decodeWind(wd *types.WindData) string {
+	switch {
+	case wd == nil:
+		return ""
+	case wd.Calm:
+		return "calm"
+	}
+	dir := fmt.Sprintf("%03d", wd.Direction)
+	if wd.Variable {
+		dir = "VRB"
+	}
+	s := fmt.Sprintf("%s/%d", dir, wd.Speed)
+	if wd.Gusts != nil {
+		s += fmt.Sprintf("G%d", *wd.Gusts)
+	}
+	return s + "KT"
+}
+
+func decodeVis(v types.Mi) string {
+	switch {
+	case v <= 0:
+		return ""
+	case v >= 99:
+		return "P6SM"
+	}
+	return fmt.Sprintf("%gSM", float64(v))
+}
+
+func decodeClouds(layers []types.CloudData) string {
+	var parts []string
+	for _, l := range layers {
+		if l.Base == 0 && (l.Coverage == "CLR" || l.Coverage == "SKC" || l.Coverage == "NSC" || l.Coverage == "NCD") {
+			parts = append(parts, l.Coverage)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s%03d", l.Coverage, l.Base/100))
+	}
+	return strings.Join(parts, " ")
+}
+
+func decodeConditions(cs []types.PIREPCondition) string {
+	var parts []string
+	for _, c := range cs {
+		s := strings.TrimSpace(strings.Join([]string{c.Frequency, c.Intensity, c.Type}, " "))
+		switch {
+		case c.Base != nil && c.Top != nil:
+			s += fmt.Sprintf(" %03d-%03d", *c.Base/100, *c.Top/100)
+		case c.Top != nil:
+			s += fmt.Sprintf(" BLO %03d", *c.Top/100)
+		case c.Base != nil:
+			s += fmt.Sprintf(" ABV %03d", *c.Base/100)
+		}
+		parts = append(parts, strings.Join(strings.Fields(s), " "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/fetch"
+	"github.com/house-holder/pilot-bar/internal/parse"
+	"github.com/house-holder/pilot-bar/internal/translate"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// explainMETAR prints the station's latest METAR in plain English, from the
+// cache when it's there and fetched otherwise
+func explainMETAR(ctx context.Context, icao string) error {
+	icao = strings.ToUpper(icao)
+	wx, err := cache.ReadStation(icao)
+	if err != nil || wx.METAR.RawOb == "" {
+		APImetar, sub, err := fetch.Source{}.GetMETARWithFallback(ctx, icao, MaxTries)
+		if err != nil {
+			return err
+		}
+		wx = types.Airport{ICAO: icao, Name: APImetar.Name, METARSub: sub}
+		if err := parse.BuildInternalMETAR(&APImetar, &wx.METAR); err != nil {
+			return err
+		}
+		wx.METAR.Reported.Epoch = APImetar.ObsTime
+	}
+
+	header := wx.ICAO
+	if wx.Name != "" {
+		header += " (" + wx.Name + ")"
+	}
+	if wx.METARSub != nil {
+		header += fmt.Sprintf(", reported by %s %.0f NM away", wx.METARSub.ICAO, wx.METARSub.DistanceNM)
+	}
+	fmt.Println(header)
+	fmt.Println(wx.METAR.RawOb)
+	fmt.Println()
+	fmt.Println(translate.METAR(wx.METAR))
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// showHistory prints a station's stored observations since the --since window
+// as a table, csv or json
+func showHistory(icao string, flags Flags) error {
+	icao = strings.ToUpper(icao)
+	window, err := parseSince(*flags.Since)
+	if err != nil {
+		return err
+	}
+
+	entries, err := cache.History(icao, time.Now().Add(-window))
+	if err != nil {
+		return err
+	}
+
+	switch *flags.Format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	case "csv":
+		return historyCSV(entries)
+	case "table", "":
+		if len(entries) == 0 {
+			fmt.Printf("No history for %s in the last %s\n", icao, *flags.Since)
+			return nil
+		}
+		return historyTable(entries)
+	default:
+		return fmt.Errorf("unknown format %q (table, csv, json)", *flags.Format)
+	}
+}
+
+// parseSince accepts Go durations plus a day suffix, e.g. "90m", "12h", "2d"
+func parseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid --since %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --since %q", s)
+	}
+	return d, nil
+}
+
+func historyTable(entries []types.Observation) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tCAT\tWIND\tVIS\tCIG\tTEMP/DEW\tALTIM\tWX")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%g\t%s\t%.1f/%.1f\t%.2f\t%s\n",
+			time.Unix(e.Epoch, 0).UTC().Format("02 1504Z"),
+			e.FltCat,
+			historyWind(e),
+			float64(e.Visibility),
+			historyCeiling(e),
+			e.Temp, e.Dewpoint,
+			float64(e.Altimeter),
+			e.WxString,
+		)
+	}
+	return w.Flush()
+}
+
+func historyCSV(entries []types.Observation) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{
+		"time", "fltcat", "wind_dir", "wind_speed", "gusts", "visibility",
+		"ceiling", "temp", "dewpoint", "altimeter", "wx", "raw",
+	})
+	for _, e := range entries {
+		ceiling := ""
+		if e.Ceiling != nil {
+			ceiling = strconv.Itoa(int(*e.Ceiling))
+		}
+		w.Write([]string{
+			time.Unix(e.Epoch, 0).UTC().Format(time.RFC3339),
+			e.FltCat,
+			strconv.Itoa(int(e.WindDir)),
+			strconv.Itoa(int(e.WindSpeed)),
+			strconv.Itoa(int(e.Gusts)),
+			strconv.FormatFloat(float64(e.Visibility), 'g', -1, 64),
+			ceiling,
+			strconv.FormatFloat(e.Temp, 'f', 1, 64),
+			strconv.FormatFloat(e.Dewpoint, 'f', 1, 64),
+			strconv.FormatFloat(float64(e.Altimeter), 'f', 2, 64),
+			e.WxString,
+			e.RawOb,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func historyWind(e types.Observation) string {
+	if e.WindSpeed == 0 {
+		return "calm"
+	}
+	s := fmt.Sprintf("%03d/%d", e.WindDir, e.WindSpeed)
+	if e.Gusts > 0 {
+		s += fmt.Sprintf("G%d", e.Gusts)
+	}
+	return s
+}
+
+func historyCeiling(e types.Observation) string {
+	if e.Ceiling == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%03d", *e.Ceiling/100)
+}
@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
+	"os"
 
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/internal/fetch"
 	"github.com/spf13/pflag"
 )
 
@@ -12,15 +18,19 @@ type Flags struct {
 	Info    *bool
 	Update  *bool
 	Verbose *bool
+	Since   *string
+	Format  *string
 }
 
-func setupFlags() Flags {
+func setupFlags(cfg *config.Config) Flags {
 	info := pflag.BoolP("info", "i", false, "enable info logging")
 	debug := pflag.BoolP("debug", "d", false, "enable debug logging")
 	update := pflag.BoolP("update", "u", false, "force update cycle")
 	verbose := pflag.BoolP("verbose", "v", false, "enable verbose output")
+	since := pflag.String("since", "24h", "history window, e.g. 90m, 12h, 2d")
+	format := pflag.String("format", "table", "output format: table, csv, json (history); table, json, plain (decode)")
 
-	defaultID, err := resolveAirport()
+	defaultID, err := resolveAirport(cfg)
 	if err != nil {
 		slog.Error("failed to resolve default airport", "error", err)
 		defaultID = "KCGI"
@@ -34,11 +44,18 @@ func setupFlags() Flags {
 		Info:    info,
 		Update:  update,
 		Verbose: verbose,
+		Since:   since,
+		Format:  format,
 	}
 }
 
 func main() {
-	flags := setupFlags()
+	cfg := config.Load()
+	// before the airport is resolved, as it may come from the legacy file
+	if err := cache.MigrateLegacy(); err != nil {
+		slog.Warn("legacy cache migration failed", "error", err)
+	}
+	flags := setupFlags(cfg)
 	InitLogger(flags)
 
 	args := pflag.Args()
@@ -47,13 +64,78 @@ func main() {
 			slog.Error("usage: pilot-bar-daemon switch <ICAO>")
 			return
 		}
-		if err := switchAirport(args[1], flags); err != nil {
+		// a running daemon owns the active airport, so hand it the switch
+		if resp, err := ctlSend(ctlRequest{Cmd: "switch", ICAO: args[1]}); err == nil {
+			if !resp.OK {
+				slog.Error("Switch", "error", resp.Error)
+				os.Exit(1)
+			}
+		} else if err := switchAirport(context.Background(), fetch.Source{}, args[1], flags, cfg); err != nil {
 			slog.Error("Switch", "error", err)
+			return
+		}
+		if wx, err := cache.Read(); err == nil {
+			fmt.Println(wx.METAR.RawOb)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "ctl" {
+		if err := runCtl(args[1:]); err != nil {
+			slog.Error("Ctl", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "history" {
+		icao := *flags.Airport
+		if len(args) > 1 {
+			icao = args[1]
+		}
+		if err := showHistory(icao, flags); err != nil {
+			slog.Error("History", "error", err)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "explain" {
+		icao := *flags.Airport
+		if len(args) > 1 {
+			icao = args[1]
+		}
+		if err := explainMETAR(context.Background(), icao); err != nil {
+			slog.Error("Explain", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "decode" {
+		if err := decodeReports(args[1:], *flags.Format); err != nil {
+			slog.Error("Decode", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "install-service" {
+		if err := installService(cfg); err != nil {
+			slog.Error("Install service", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "run" {
+		if err := runDaemon(fetch.Source{}, flags, cfg); err != nil {
+			slog.Error("Run", "error", err)
+			os.Exit(1)
 		}
 		return
 	}
 
-	if err := Update(flags); err != nil {
+	if err := Update(context.Background(), fetch.Source{}, flags, cfg); err != nil {
 		slog.Error("Update", "error", err)
 	}
 }
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/internal/fetch"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+const (
+	pidFile = "daemon.pid"
+
+	// bounds on the scheduler's sleep between cycles
+	minWake = 15 * time.Second
+	maxWake = 15 * time.Minute
+)
+
+// daemon is the state of a resident run; only the run loop goroutine
+// touches it
+type daemon struct {
+	src     fetch.Source
+	flags   Flags
+	cfg     *config.Config
+	started time.Time
+	nextRun time.Time
+	lastErr error
+	events  *eventHub
+	ready   bool // READY=1 has been sent
+}
+
+// runDaemon keeps the cache fresh until SIGINT/SIGTERM, waking whenever the
+// next product falls due or a control request arrives. SIGHUP reloads the
+// config and SIGUSR1 forces a full refresh.
+func runDaemon(src fetch.Source, flags Flags, cfg *config.Config) error {
+	release, err := acquirePidfile()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP, syscall.SIGUSR1)
+	defer signal.Stop(hup)
+
+	requests, closeCtl, err := serveControl(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeCtl()
+
+	d := &daemon{src: src, flags: flags, cfg: cfg, started: time.Now(), events: newEventHub()}
+	if cfg.API.Listen != "" {
+		closeAPI, err := serveAPI(ctx, cfg.API, d.events)
+		if err != nil {
+			return err
+		}
+		defer closeAPI()
+	}
+	slog.Info("Daemon started", "airport", *flags.Airport)
+
+	// pings come from the loop itself, so a hung cycle trips the watchdog
+	var watchdog <-chan time.Time
+	if interval := watchdogInterval(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.Info("Daemon stopping")
+			notify("STOPPING=1")
+			return nil
+		case <-watchdog:
+			notify("WATCHDOG=1")
+			continue
+		case <-timer.C:
+			d.cycle(ctx)
+		case req := <-requests:
+			req.reply <- d.handle(ctx, req.ctlRequest)
+		case sig := <-hup:
+			d.signal(ctx, sig)
+		}
+
+		wait := nextWake(*d.flags.Airport, d.cfg.Modules, time.Now())
+		d.nextRun = time.Now().Add(wait)
+		slog.Debug("Sleeping", "for", wait.String())
+		timer.Reset(wait)
+		d.reportStatus()
+	}
+}
+
+// reportStatus tells systemd how the last cycle went, and that the daemon
+// is ready once the first one has run, whether or not it fetched
+func (d *daemon) reportStatus() {
+	status := "STATUS=" + d.statusLine()
+	if !d.ready {
+		status = "READY=1\n" + status
+		d.ready = true
+	}
+	notify(status + "\nWATCHDOG=1")
+}
+
+// cycle runs one update and pokes waybar and API subscribers if anything new
+// was written
+func (d *daemon) cycle(ctx context.Context) {
+	before, _ := cache.ReadStation(*d.flags.Airport)
+	d.lastErr = TryUpdate(ctx, d.src, d.flags, d.cfg)
+	if errors.Is(d.lastErr, cache.ErrLocked) {
+		// another process is updating; a forced refresh waits for the next cycle
+		slog.Warn("Cache busy, skipping cycle")
+		return
+	}
+	if d.lastErr != nil {
+		slog.Error("Update", "error", d.lastErr)
+	}
+	if after := d.publish(before); after.LastUpdateEpoch != before.LastUpdateEpoch {
+		signalWaybar()
+	}
+	*d.flags.Update = false // --update and refresh force one cycle only
+}
+
+func (d *daemon) signal(ctx context.Context, sig os.Signal) {
+	switch sig {
+	case syscall.SIGHUP:
+		if err := d.reload(ctx); err != nil {
+			slog.Error("Reload", "error", err)
+		}
+	case syscall.SIGUSR1:
+		slog.Info("Forced refresh requested")
+		*d.flags.Update = true
+		d.cycle(ctx)
+	}
+}
+
+// reload re-reads config.json. A changed config airport switches station, and
+// changed intervals or modules take effect straight away; the API address
+// only applies on restart.
+func (d *daemon) reload(ctx context.Context) error {
+	old := d.cfg
+	d.cfg = config.Load()
+	slog.Info("Config reloaded")
+
+	if d.cfg.API.Listen != old.API.Listen {
+		slog.Warn("API address change needs a restart", "listen", d.cfg.API.Listen)
+	}
+
+	icao := strings.ToUpper(d.cfg.Airport)
+	if icao != "" && icao != strings.ToUpper(old.Airport) && icao != *d.flags.Airport {
+		if err := d.switchTo(ctx, icao); err != nil {
+			return err
+		}
+	}
+	intervalsChanged := !maps.Equal(d.cfg.Intervals, old.Intervals)
+	if intervalsChanged {
+		if err := reschedule(*d.flags.Airport, d.cfg.Intervals); err != nil {
+			return err
+		}
+	}
+	if intervalsChanged || d.cfg.Modules != old.Modules {
+		d.cycle(ctx)
+	}
+	return nil
+}
+
+// switchTo makes icao the active station and tells API subscribers
+func (d *daemon) switchTo(ctx context.Context, icao string) error {
+	before, _ := cache.ReadStation(*d.flags.Airport)
+	err := switchAirport(ctx, d.src, icao, d.flags, d.cfg)
+	d.publish(before)
+	return err
+}
+
+// reschedule recomputes each fetched product's expiry from its last fetch and
+// the new intervals; failed products keep their retry time
+func reschedule(icao string, intervals map[string]int) error {
+	unlock, err := cache.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	wx, err := cache.ReadStation(icao)
+	if err != nil {
+		return err
+	}
+	for p, state := range wx.Products {
+		if state.FetchedAt == 0 || state.LastError != "" {
+			continue
+		}
+		state.Expires = state.FetchedAt + int64(intervals[p])
+		wx.Products[p] = state
+	}
+	return cache.Write(wx)
+}
+
+// publish sends an event for whatever changed since before and returns the
+// current entry
+func (d *daemon) publish(before types.Airport) types.Airport {
+	after, err := cache.ReadStation(*d.flags.Airport)
+	if err != nil {
+		return before
+	}
+	if changed := changedProducts(before, after); len(changed) > 0 {
+		d.events.publish(after, changed)
+	}
+	return after
+}
+
+// nextWake sleeps until the soonest product expiry, or the next routine-window
+// poll if that comes first
+func nextWake(icao string, modules config.ModuleCfg, now time.Time) time.Duration {
+	if !slices.ContainsFunc(products, func(p string) bool { return enabled(modules, p) }) {
+		return maxWake
+	}
+	wx, err := cache.ReadStation(icao)
+	if err != nil || len(wx.Products) == 0 {
+		return minWake
+	}
+
+	next := now.Add(maxWake).Unix()
+	for _, p := range products {
+		if state, ok := wx.Products[p]; ok && state.Expires < next {
+			next = state.Expires
+		}
+	}
+
+	d := &UpdateData{cached: wx, now: now.Unix()}
+	if routine := nextRoutinePoll(d, now); modules.METAR && routine < next {
+		next = routine
+	}
+
+	wait := time.Duration(next-now.Unix()) * time.Second
+	return min(max(wait, minWake), maxWake)
+}
+
+// nextRoutinePoll is when AwaitingRoutine will next be true, assuming the
+// cached METAR doesn't change first
+func nextRoutinePoll(d *UpdateData, now time.Time) int64 {
+	windowStart := now.UTC().Truncate(time.Hour).Add(RoutineStart * time.Minute)
+	if now.UTC().Minute() < RoutineStart {
+		return windowStart.Unix()
+	}
+	if d.cached.METAR.Reported.Epoch >= windowStart.Unix() {
+		// already have this hour's report
+		return windowStart.Add(time.Hour).Unix()
+	}
+	return d.cached.Products[types.ProductMETAR].CheckedAt + RoutinePoll
+}
+
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "pilot-bar")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("pilot-bar-%d", os.Getuid()))
+}
+
+// acquirePidfile holds a non-blocking lock on the pidfile for the life of the
+// process, so a second daemon exits instead of doubling every fetch
+func acquirePidfile() (release func(), err error) {
+	dir := runtimeDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("runtime dir: %w", err)
+	}
+	path := filepath.Join(dir, pidFile)
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("pidfile: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("daemon already running (pid %s)", readPid(path))
+		}
+		return nil, fmt.Errorf("pidfile lock: %w", err)
+	}
+
+	f.Truncate(0)
+	fmt.Fprintf(f, "%d\n", os.Getpid())
+	// the file stays: unlinking it would let a new daemon lock a fresh file
+	// while another still holds this one
+	return func() {
+		f.Truncate(0)
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+func readPid(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "unknown"
+	}
+	pid := strings.TrimSpace(string(data))
+	if _, err := strconv.Atoi(pid); err != nil {
+		return "unknown"
+	}
+	return pid
+}
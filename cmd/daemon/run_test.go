@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAcquirePidfile(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	path := filepath.Join(runtimeDir(), pidFile)
+
+	release, err := acquirePidfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readPid(path); got != strconv.Itoa(os.Getpid()) {
+		t.Errorf("pidfile holds %q, want our pid", got)
+	}
+	if _, err := acquirePidfile(); err == nil || !strings.Contains(err.Error(), "already running") {
+		t.Fatalf("second acquire: err = %v, want already running", err)
+	}
+
+	release()
+	// the file outlives the lock, so a waiting daemon locks the same inode
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("pidfile removed on release: %v", err)
+	}
+	if got := readPid(path); got != "unknown" {
+		t.Errorf("released pidfile holds %q, want no pid", got)
+	}
+
+	release, err = acquirePidfile()
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	release()
+}
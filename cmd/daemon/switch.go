@@ -1,42 +1,48 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"os/exec"
 	"strings"
 
 	"github.com/house-holder/pilot-bar/internal/cache"
-	"github.com/house-holder/pilot-bar/pkg/types"
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/internal/fetch"
 )
 
 const waybarSignal = 8
 
-func switchAirport(icao string, flags Flags) error {
+func switchAirport(ctx context.Context, src fetch.Source, icao string, flags Flags, cfg *config.Config) error {
 	icao = strings.ToUpper(icao)
-	if len(icao) != 4 {
-		return fmt.Errorf("invalid ICAO identifier: %q (expected 4 characters)", icao)
+	if !cache.ValidICAO(icao) {
+		return fmt.Errorf("invalid ICAO identifier: %q (expected 3-4 letters or digits)", icao)
 	}
 
 	slog.Info("Switching airport", "icao", icao)
 
-	if err := cache.Write(types.Airport{
-		ICAO: icao,
-		METAR: types.METAR{
-			Reported: types.Timestamp{Epoch: 0},
-		},
-	}); err != nil {
-		return fmt.Errorf("cache reset failed: %w", err)
+	unlock, err := cache.Lock()
+	if err != nil {
+		return err
 	}
 
-	*flags.Airport = icao
-	if err := Update(flags); err != nil {
-		return err
+	// a station cached earlier shows straight away; update then only
+	// refreshes whatever has expired
+	if err := cache.SetActive(icao); err != nil {
+		unlock()
+		return fmt.Errorf("set active failed: %w", err)
+	}
+	if _, err := cache.ReadStation(icao); err == nil {
+		signalWaybar()
 	}
 
-	wx, err := cache.Read()
-	if err == nil {
-		fmt.Println(wx.METAR.RawOb)
+	*flags.Airport = icao
+	alerts, err := update(ctx, src, flags, cfg)
+	unlock()
+	deliverAlerts(cfg.Alerts, alerts)
+	if err != nil {
+		return err
 	}
 
 	signalWaybar()
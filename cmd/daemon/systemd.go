@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+const (
+	serviceName = "pilot-bar"
+	timerName   = "pilot-bar-update"
+
+	// generated units allow this long between watchdog pings, enough for a
+	// full cycle of fetch retries
+	unitWatchdog = 5 * time.Minute
+)
+
+// sdNotify sends a state message such as "READY=1" to systemd; it's a no-op
+// outside a Type=notify unit
+func sdNotify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	if addr[0] == '@' {
+		addr = "\x00" + addr[1:] // abstract namespace
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("sd_notify: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return fmt.Errorf("sd_notify: %w", err)
+	}
+	return nil
+}
+
+func notify(state string) {
+	if err := sdNotify(state); err != nil {
+		slog.Debug("systemd notify failed", "error", err)
+	}
+}
+
+// watchdogInterval is how often to ping WATCHDOG=1: half of WATCHDOG_USEC,
+// or zero when systemd isn't watching this process
+func watchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// statusLine summarises the last cycle for STATUS=, e.g.
+// "KCGI: metar 1852Z, next check 1904Z"
+func (d *daemon) statusLine() string {
+	icao := *d.flags.Airport
+	if d.lastErr != nil {
+		// a joined error spans lines, and each line is its own assignment
+		msg := strings.ReplaceAll(d.lastErr.Error(), "\n", "; ")
+		return fmt.Sprintf("%s: update failed: %s", icao, msg)
+	}
+	wx, err := cache.ReadStation(icao)
+	if err != nil {
+		return icao + ": no data yet"
+	}
+	s := icao + ": "
+	if state, ok := wx.Products[types.ProductMETAR]; ok && state.FetchedAt > 0 {
+		s += "metar " + time.Unix(state.FetchedAt, 0).UTC().Format("1504Z")
+	} else {
+		s += "no metar yet"
+	}
+	if !d.nextRun.IsZero() {
+		s += ", next check " + d.nextRun.UTC().Format("1504Z")
+	}
+	return s
+}
+
+// installService writes a resident Type=notify service plus a oneshot
+// service and timer for cron-style use; enable one or the other
+func installService(cfg *config.Config) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("executable path: %w", err)
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("home dir: %w", err)
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	dir = filepath.Join(dir, "systemd", "user")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("unit dir: %w", err)
+	}
+
+	units := map[string]string{
+		serviceName + ".service": fmt.Sprintf(`[Unit]
+Description=pilot-bar weather daemon
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=notify
+ExecStart=%s run
+ExecReload=/bin/kill -HUP $MAINPID
+WatchdogSec=%d
+Restart=on-failure
+RestartSec=30
+
+[Install]
+WantedBy=default.target
+`, unitQuote(exe), int(unitWatchdog.Seconds())),
+
+		timerName + ".service": fmt.Sprintf(`[Unit]
+Description=pilot-bar weather update
+
+[Service]
+Type=oneshot
+ExecStart=%s
+`, unitQuote(exe)),
+
+		timerName + ".timer": fmt.Sprintf(`[Unit]
+Description=Periodic pilot-bar weather update
+
+[Timer]
+OnStartupSec=30
+OnUnitActiveSec=%d
+
+[Install]
+WantedBy=timers.target
+`, max(cfg.Intervals[types.ProductMETAR], RoutinePoll)),
+	}
+
+	for name, body := range units {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		slog.Info("Wrote unit", "path", path)
+	}
+
+	fmt.Println(strings.Join([]string{
+		"Units written to " + dir,
+		"Resident daemon:  systemctl --user daemon-reload && systemctl --user enable --now " + serviceName + ".service",
+		"Periodic updates: systemctl --user daemon-reload && systemctl --user enable --now " + timerName + ".timer",
+	}, "\n"))
+	return nil
+}
+
+// unitQuote quotes a path for an Exec line, so spaces survive and "%" and
+// "$" aren't taken as specifiers or variables
+func unitQuote(path string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "%", "%%", "$", "$$")
+	return `"` + r.Replace(path) + `"`
+}
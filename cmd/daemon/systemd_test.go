@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/internal/fetch"
+)
+
+// fakeNotifySocket binds a unixgram socket and points NOTIFY_SOCKET at it.
+// It lives under a short temp dir, as socket paths are capped near 108 bytes.
+func fakeNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "sdn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", path)
+	return conn
+}
+
+func readDatagram(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	if err := conn.SetReadDeadline(time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("no notify message: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestReportStatus(t *testing.T) {
+	conn := fakeNotifySocket(t)
+	icao := "KCGI"
+	d := &daemon{flags: Flags{Airport: &icao}, lastErr: errors.New("offline")}
+
+	d.reportStatus()
+	first := strings.Split(readDatagram(t, conn), "\n")
+	want := []string{"READY=1", "STATUS=KCGI: update failed: offline", "WATCHDOG=1"}
+	if strings.Join(first, "|") != strings.Join(want, "|") {
+		t.Errorf("first message = %q, want %q", first, want)
+	}
+
+	d.reportStatus()
+	second := readDatagram(t, conn)
+	if strings.Contains(second, "READY=1") {
+		t.Errorf("READY=1 sent twice: %q", second)
+	}
+	if !strings.HasPrefix(second, "STATUS=") || !strings.HasSuffix(second, "\nWATCHDOG=1") {
+		t.Errorf("second message = %q, want STATUS and WATCHDOG", second)
+	}
+}
+
+func TestStatusLineJoinsErrors(t *testing.T) {
+	icao := "KCGI"
+	d := &daemon{flags: Flags{Airport: &icao}, lastErr: errors.Join(errors.New("metar: timeout"), errors.New("taf: 503"))}
+
+	got := d.statusLine()
+	if strings.Contains(got, "\n") {
+		t.Fatalf("status line spans lines: %q", got)
+	}
+	if want := "KCGI: update failed: metar: timeout; taf: 503"; got != want {
+		t.Errorf("status line = %q, want %q", got, want)
+	}
+}
+
+func TestTryUpdateSkipsWhenLocked(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	unlock, err := cache.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	icao, force := "KCGI", true
+	done := make(chan error, 1)
+	go func() {
+		done <- TryUpdate(context.Background(), fetch.Source{}, Flags{Airport: &icao, Update: &force}, &config.Config{})
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, cache.ErrLocked) {
+			t.Errorf("err = %v, want cache.ErrLocked", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("TryUpdate blocked on a held lock")
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	conn := fakeNotifySocket(t)
+	t.Setenv("NOTIFY_SOCKET", "")
+
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatalf("sdNotify without NOTIFY_SOCKET: %v", err)
+	}
+	if err := conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := conn.Read(make([]byte, 64)); err == nil {
+		t.Errorf("got %d bytes with NOTIFY_SOCKET unset", n)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		usec, pid string
+		want      time.Duration
+	}{
+		{"", "", 0},
+		{"junk", "", 0},
+		{"60000000", "", 30 * time.Second},
+		{"60000000", "1", 0}, // meant for another process
+	}
+	for _, tt := range tests {
+		t.Setenv("WATCHDOG_USEC", tt.usec)
+		t.Setenv("WATCHDOG_PID", tt.pid)
+		if got := watchdogInterval(); got != tt.want {
+			t.Errorf("watchdogInterval(%q, %q) = %v, want %v", tt.usec, tt.pid, got, tt.want)
+		}
+	}
+}
+
+func TestUnitQuote(t *testing.T) {
+	tests := map[string]string{
+		"/usr/bin/pilot-bar-daemon":      `"/usr/bin/pilot-bar-daemon"`,
+		"/home/a b/bin/pilot-bar-daemon": `"/home/a b/bin/pilot-bar-daemon"`,
+		`/opt/100%/$HOME/"x"`:            `"/opt/100%%/$$HOME/\"x\""`,
+	}
+	for in, want := range tests {
+		if got := unitQuote(in); got != want {
+			t.Errorf("unitQuote(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
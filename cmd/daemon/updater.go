@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/house-holder/pilot-bar/internal/alert"
 	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/config"
 	"github.com/house-holder/pilot-bar/internal/fetch"
+	"github.com/house-holder/pilot-bar/internal/geo"
 	"github.com/house-holder/pilot-bar/internal/parse"
 	"github.com/house-holder/pilot-bar/pkg/types"
 )
@@ -13,73 +21,379 @@ import (
 const (
 	MaxTries = 5
 
-	// NOTE: seconds vals below, 1min for testing - must edit for prod
-	UpdateInterval = 60
-	IntervalMETAR  = 60
-	IntervalTAF    = 60
-	IntervalAFD    = 60
+	// seconds before retrying a product whose last fetch failed
+	ErrorRetry = 60
+
+	PIREPRadius = 100 // nautical miles
+	PIREPAge    = 2   // hours
+
+	AloftForecast = "06" // FB period: 06, 12 or 24 hours
+
+	// routine METARs are issued around :50-:59; inside that window the METAR
+	// is polled every RoutinePoll seconds until the new one arrives
+	RoutineStart = 50
+	RoutinePoll  = 60
+
+	// shared deadline for one update; products still out when it passes are
+	// recorded as failed and retried after ErrorRetry
+	CycleTimeout = 90 * time.Second
 )
 
+// FB site search radii, widened when nothing is found close by
+var aloftSearchNM = []float64{150, 400}
+
+// refresh order; later products depend on the station data the first two set
+var products = []string{
+	types.ProductMETAR, types.ProductStation, types.ProductTAF, types.ProductAFD,
+	types.ProductPIREP, types.ProductHazards, types.ProductAloft,
+}
+
 type UpdateData struct {
-	cached        types.Airport
-	requested     string
-	now           int64
-	intervalMETAR int64
-	intervalTAF   int64
-	intervalAFD   int64
+	cached    types.Airport
+	now       int64
+	force     bool
+	intervals map[string]int
+	modules   config.ModuleCfg
+	alerts    []alert.Alert // to deliver once the lock is released
+	src       fetch.Source
 }
 
-func (d *UpdateData) ICAOChanged() bool {
-	return d.cached.ICAO != d.requested
+// enabled reports whether product's module is switched on. The station
+// product does the CWA lookup for the AFD, and locates the airport when no
+// METAR is fetched to do it.
+func enabled(m config.ModuleCfg, product string) bool {
+	switch product {
+	case types.ProductMETAR:
+		return m.METAR
+	case types.ProductTAF:
+		return m.TAF
+	case types.ProductAFD:
+		return m.AFD
+	case types.ProductStation:
+		return m.AFD || (!m.METAR && (m.TAF || m.PIREP || m.AIRMET || m.Aloft))
+	case types.ProductPIREP:
+		return m.PIREP
+	case types.ProductHazards:
+		return m.AIRMET
+	case types.ProductAloft:
+		return m.Aloft
+	}
+	return false
 }
 
-func (d *UpdateData) METAREmpty() bool {
-	return d.cached.METAR.Reported.Epoch == 0
+// clearDisabled drops data and refresh state for switched-off modules, so
+// the cache holds only what's wanted. It reports whether anything went.
+func clearDisabled(wx *types.Airport, m config.ModuleCfg) bool {
+	cleared := false
+	for _, p := range products {
+		if enabled(m, p) {
+			continue
+		}
+		if _, ok := wx.Products[p]; !ok {
+			continue
+		}
+		slog.Debug("Clearing disabled module", "product", p)
+		delete(wx.Products, p)
+		cleared = true
+
+		switch p {
+		case types.ProductMETAR:
+			wx.METAR = types.METAR{}
+			wx.METARSub = nil
+		case types.ProductTAF:
+			wx.RawTAF = ""
+			wx.TAFSub = nil
+		case types.ProductAFD:
+			wx.RawAFD = ""
+			wx.AFD = types.AFD{}
+		case types.ProductStation:
+			wx.CWA = ""
+		case types.ProductPIREP:
+			wx.PIREPs = nil
+		case types.ProductHazards:
+			wx.Hazards = nil
+		case types.ProductAloft:
+			wx.WindsAloft = types.WindsAloft{}
+		}
+	}
+	return cleared
 }
 
-func (d *UpdateData) TimeExpired() bool {
-	return d.now-d.cached.LastUpdateEpoch > UpdateInterval
+func (d *UpdateData) Expired(product string) bool {
+	state, ok := d.cached.Products[product]
+	return !ok || d.now >= state.Expires
 }
 
-func (d *UpdateData) NeedsAnyUpdate(force bool) bool {
-	if force || d.ICAOChanged() || d.METAREmpty() || d.TimeExpired() {
-		slog.Debug("Proceeding with update", "list", map[string]any{
-			"New ID":       d.ICAOChanged(),
-			"Timeout":      d.TimeExpired(),
-			"Force update": force,
-		})
+// Due is true when product is enabled and should be fetched this cycle
+func (d *UpdateData) Due(product string) bool {
+	if !enabled(d.modules, product) {
+		return false
+	}
+	if product == types.ProductMETAR && d.AwaitingRoutine() {
+		return true
+	}
+	return d.force || d.Expired(product)
+}
+
+// AwaitingRoutine is true inside the routine window while the cached METAR
+// predates it, once RoutinePoll has passed since the last try
+func (d *UpdateData) AwaitingRoutine() bool {
+	now := time.Unix(d.now, 0).UTC()
+	if now.Minute() < RoutineStart {
+		return false
+	}
+	windowStart := now.Truncate(time.Hour).Add(RoutineStart * time.Minute).Unix()
+	state := d.cached.Products[types.ProductMETAR]
+	return d.cached.METAR.Reported.Epoch < windowStart && d.now-state.CheckedAt >= RoutinePoll
+}
+
+func (d *UpdateData) NeedsAnyUpdate() bool {
+	due := make(map[string]any, len(products))
+	needed := false
+	for _, p := range products {
+		due[p] = d.Due(p)
+		needed = needed || d.Due(p)
+	}
+	if needed {
+		slog.Debug("Proceeding with update", "list", due)
 		return true
 	}
 	slog.Debug("No update needed")
 	return false
 }
 
-func Update(flags Flags) error {
-	if err := cache.EnsureExists(*flags.Airport); err != nil {
-		return err
+// record stores the outcome of a product fetch. Failures keep the old data and
+// retry sooner than the product's normal interval.
+func (d *UpdateData) record(wx *types.Airport, product string, err error) {
+	if wx.Products == nil {
+		wx.Products = make(map[string]types.ProductState)
+	}
+	state := wx.Products[product]
+	state.CheckedAt = d.now
+	if err != nil {
+		slog.Warn("Product update failed", "product", product, "error", err)
+		state.LastError = err.Error()
+		state.Expires = d.now + int64(min(ErrorRetry, d.intervals[product]))
+	} else {
+		state.LastError = ""
+		state.FetchedAt = d.now
+		state.Expires = d.now + int64(d.intervals[product])
 	}
+	wx.Products[product] = state
+}
+
+// Update refreshes the cache under its lock, so overlapping runs (cron plus
+// switch) queue up instead of clobbering each other
+func Update(ctx context.Context, src fetch.Source, flags Flags, cfg *config.Config) error {
+	return lockedUpdate(ctx, cache.Lock, src, flags, cfg)
+}
 
-	cachedWX, err := cache.Read()
+// TryUpdate is Update for the run loop, which mustn't block on the lock while
+// the watchdog waits; it returns cache.ErrLocked when another process holds it
+func TryUpdate(ctx context.Context, src fetch.Source, flags Flags, cfg *config.Config) error {
+	return lockedUpdate(ctx, cache.TryLock, src, flags, cfg)
+}
+
+func lockedUpdate(ctx context.Context, lock func() (func(), error), src fetch.Source, flags Flags, cfg *config.Config) error {
+	unlock, err := lock()
 	if err != nil {
 		return err
 	}
+	alerts, err := update(ctx, src, flags, cfg)
+	unlock()
+	deliverAlerts(cfg.Alerts, alerts)
+	return err
+}
+
+// update does the work of Update; callers must hold the cache lock, and
+// deliver the returned alerts after releasing it. Due products are fetched
+// concurrently, all under one CycleTimeout deadline.
+func update(ctx context.Context, src fetch.Source, flags Flags, cfg *config.Config) ([]alert.Alert, error) {
+	if err := cache.EnsureExists(*flags.Airport); err != nil {
+		return nil, err
+	}
+
+	if err := cache.SetActive(*flags.Airport); err != nil {
+		return nil, err
+	}
+
+	cachedWX, err := cache.ReadStation(*flags.Airport)
+	if err != nil {
+		return nil, err
+	}
 
 	d := &UpdateData{
-		cached:        cachedWX,
-		requested:     *flags.Airport,
-		now:           time.Now().Unix(),
-		intervalMETAR: IntervalMETAR,
-		intervalTAF:   IntervalTAF,
-		intervalAFD:   IntervalAFD,
+		cached:    cachedWX,
+		now:       time.Now().Unix(),
+		force:     *flags.Update,
+		intervals: cfg.Intervals,
+		modules:   cfg.Modules,
+		src:       src,
+	}
+
+	if clearDisabled(&cachedWX, cfg.Modules) && !d.NeedsAnyUpdate() {
+		return nil, cache.Write(cachedWX)
+	}
+	if !d.NeedsAnyUpdate() {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, CycleTimeout)
+	defer cancel()
+
+	due := slices.DeleteFunc(slices.Clone(products), func(p string) bool { return !d.Due(p) })
+
+	// the METAR and station lookup locate the airport and find its CWA; while
+	// either is unknown the rest wait for them
+	first, rest := due, []string(nil)
+	if (cachedWX.Lat == 0 && cachedWX.Lon == 0) || (cachedWX.CWA == "" && slices.Contains(due, types.ProductAFD)) {
+		locating := []string{types.ProductMETAR, types.ProductStation}
+		first = slices.DeleteFunc(slices.Clone(due), func(p string) bool { return !slices.Contains(locating, p) })
+		rest = slices.DeleteFunc(slices.Clone(due), func(p string) bool { return slices.Contains(locating, p) })
+	}
+
+	errs := d.fetchAll(ctx, &cachedWX, first, flags, cfg)
+	if cachedWX.Lat == 0 && cachedWX.Lon == 0 {
+		// nothing else can run without a location
+		writeErr := cache.Write(cachedWX)
+		if err := errors.Join(errs[types.ProductMETAR], errs[types.ProductStation]); err != nil {
+			return d.alerts, errors.Join(err, writeErr)
+		}
+		return d.alerts, errors.Join(fmt.Errorf("no location for %s", cachedWX.ICAO), writeErr)
+	}
+	d.fetchAll(ctx, &cachedWX, rest, flags, cfg)
+
+	cachedWX.LastUpdateEpoch = time.Now().Unix()
+	if err := cache.Write(cachedWX); err != nil {
+		return d.alerts, err
+	}
+
+	maxAge := time.Duration(cfg.Cache.MaxAgeHours) * time.Hour
+	if err := cache.Prune(cfg.Cache.MaxStations, maxAge); err != nil {
+		slog.Warn("Cache prune failed", "error", err)
+	}
+	return d.alerts, nil
+}
+
+// result is one product's fetch outcome. apply merges whatever was fetched
+// into the entry and is nil when there's nothing to keep.
+type result struct {
+	product string
+	apply   func(wx *types.Airport)
+	err     error
+}
+
+// fetchAll fetches the batch concurrently and merges each result into wx as
+// it lands, so wx is only ever touched from this goroutine. A METAR that
+// lands while others are still out is written straight away, so the bar
+// doesn't wait on slower products.
+func (d *UpdateData) fetchAll(ctx context.Context, wx *types.Airport, batch []string, flags Flags, cfg *config.Config) map[string]error {
+	snapshot := *wx
+	snapshot.Products = nil // fetchers read the entry but never its state
+
+	results := make(chan result)
+	for _, p := range batch {
+		go func() { results <- fetchProduct(ctx, d.src, p, snapshot, flags, cfg) }()
 	}
 
-	if !d.NeedsAnyUpdate(*flags.Update) {
-		return nil
+	errs := make(map[string]error)
+	for pending := len(batch); pending > 0; pending-- {
+		r := <-results
+		prev := types.NewObservation(wx.METAR)
+		if r.apply != nil {
+			r.apply(wx)
+		}
+		d.record(wx, r.product, r.err)
+		errs[r.product] = r.err
+
+		if r.product != types.ProductMETAR || r.err != nil {
+			continue
+		}
+		maxAge := time.Duration(cfg.History.RetentionHours) * time.Hour
+		obs := types.NewObservation(wx.METAR)
+		if err := cache.AppendHistory(wx.ICAO, obs, maxAge, cfg.History.MaxEntries); err != nil {
+			slog.Warn("History append failed", "error", err)
+		}
+		d.alerts = append(d.alerts, checkAlerts(cfg.Alerts, wx.ICAO, prev, obs)...)
+
+		if pending > 1 {
+			wx.LastUpdateEpoch = time.Now().Unix()
+			if err := cache.Write(*wx); err != nil {
+				slog.Warn("Early METAR write failed", "error", err)
+			} else {
+				signalWaybar()
+			}
+		}
+	}
+	return errs
+}
+
+// fetchProduct does the network side of one product from a snapshot of the
+// entry; it runs on its own goroutine
+func fetchProduct(ctx context.Context, src fetch.Source, product string, wx types.Airport, flags Flags, cfg *config.Config) result {
+	r := result{product: product}
+	station := geo.Point{Lat: wx.Lat, Lon: wx.Lon}
+
+	switch product {
+	case types.ProductMETAR:
+		r.apply, r.err = updateMETAR(ctx, src, wx.METAR, flags)
+
+	case types.ProductStation:
+		r.apply, r.err = updateStation(ctx, src, wx, *flags.Airport, cfg.Modules.AFD)
+
+	case types.ProductTAF:
+		APItaf, tafSub, err := src.GetTAFWithFallback(ctx, *flags.Airport, station, MaxTries)
+		if r.err = err; err == nil {
+			r.apply = func(wx *types.Airport) {
+				wx.RawTAF = APItaf.RawTAF
+				wx.TAFSub = tafSub
+			}
+		}
+
+	case types.ProductAFD:
+		if wx.CWA == "" {
+			r.err = errors.New("no forecast office for AFD")
+			break
+		}
+		afd, err := src.GetAFD(ctx, wx.CWA)
+		if r.err = err; err == nil {
+			r.apply = func(wx *types.Airport) {
+				wx.RawAFD = afd
+				wx.AFD = parse.ParseAFD(afd)
+			}
+		}
+
+	case types.ProductPIREP:
+		APIpireps, err := src.GetPIREPs(ctx, station.Lat, station.Lon, PIREPRadius, PIREPAge, MaxTries)
+		if r.err = err; err == nil {
+			pireps := make([]types.PIREP, 0, len(APIpireps))
+			for i := range APIpireps {
+				pireps = append(pireps, parse.BuildInternalPIREP(&APIpireps[i], station))
+			}
+			r.apply = func(wx *types.Airport) { wx.PIREPs = pireps }
+		}
+
+	case types.ProductHazards:
+		// a partial list still replaces the old one; the error retries soon
+		hazards, err := updateHazards(ctx, src, station, cfg.Hazards.RadiusNM)
+		if r.err = err; hazards != nil {
+			r.apply = func(wx *types.Airport) { wx.Hazards = hazards }
+		}
+
+	case types.ProductAloft:
+		aloft, err := updateWindsAloft(ctx, src, station, wx.WindsAloft)
+		r.err = err
+		r.apply = func(wx *types.Airport) { wx.WindsAloft = aloft }
 	}
+	return r
+}
 
-	APImetar, err := fetch.GetMETAR(*flags.Airport, MaxTries)
+// updateMETAR fetches and decodes the current observation, along with the
+// station details that ride along with it
+func updateMETAR(ctx context.Context, src fetch.Source, current types.METAR, flags Flags) (func(*types.Airport), error) {
+	APImetar, metarSub, err := src.GetMETARWithFallback(ctx, *flags.Airport, MaxTries)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if *flags.Verbose {
@@ -88,50 +402,110 @@ func Update(flags Flags) error {
 		slog.Debug("", "metar", APImetar.RawOb)
 	}
 
-	if err := parse.BuildInternalMETAR(&APImetar, &cachedWX.METAR); err != nil {
-		return err
+	metar := current
+	if err := parse.BuildInternalMETAR(&APImetar, &metar); err != nil {
+		return nil, err
 	}
+	metar.Reported.Epoch = int64(APImetar.ObsTime)
 
-	if d.ICAOChanged() || cachedWX.CWA == "" {
-		cachedWX.ICAO = *flags.Airport
-		cachedWX.Elevation = types.Feet(float64(APImetar.Elev) * 3.28084)
+	return func(wx *types.Airport) {
+		wx.METAR = metar
+		wx.METARSub = metarSub
+		wx.Name = APImetar.Name
+		wx.Lat = APImetar.Lat
+		wx.Lon = APImetar.Long
+		wx.Elevation = parse.Elevation(APImetar.Elev)
+	}, nil
+}
 
-		cwa, err := fetch.LookupCWA(APImetar.Lat, APImetar.Long)
+// updateStation locates the airport when no METAR has, and looks up its
+// forecast office when the AFD wants it. A location found before the CWA
+// lookup fails is still kept.
+func updateStation(ctx context.Context, src fetch.Source, wx types.Airport, icao string, lookupCWA bool) (func(*types.Airport), error) {
+	located := wx.Lat != 0 || wx.Lon != 0
+	var info types.StationInfo
+	if !located {
+		var err error
+		info, err = src.GetAirportInfo(ctx, icao, MaxTries)
 		if err != nil {
-			slog.Warn("CWA lookup failed", "error", err)
-		} else {
-			cachedWX.CWA = cwa
+			return nil, fmt.Errorf("locating %s failed: %w", icao, err)
 		}
+		wx.Lat, wx.Lon = info.Lat, info.Lon
 	}
 
-	APItaf, err := fetch.GetTAF(*flags.Airport, MaxTries)
-	if err != nil {
-		slog.Warn("TAF fetch failed", "error", err)
-	} else {
-		cachedWX.RawTAF = APItaf.RawTAF
+	var cwa string
+	var err error
+	if lookupCWA {
+		cwa, err = src.LookupCWA(ctx, wx.Lat, wx.Lon)
 	}
 
-	if cachedWX.CWA != "" {
-		afd, err := fetch.GetAFD(cachedWX.CWA)
-		if err != nil {
-			slog.Warn("AFD fetch failed", "error", err)
-		} else {
-			cachedWX.RawAFD = afd
+	return func(wx *types.Airport) {
+		if !located {
+			wx.Name = info.Name
+			wx.Lat, wx.Lon = info.Lat, info.Lon
+			wx.Elevation = parse.Elevation(info.Elev)
+		}
+		if cwa != "" {
+			wx.CWA = cwa
 		}
+	}, err
+}
+
+// updateHazards collects active hazards. A failed feed is reported, but the
+// others are still checked; the list is nil only if every feed failed.
+func updateHazards(ctx context.Context, src fetch.Source, station geo.Point, radiusNM float64) ([]types.Hazard, error) {
+	sigmets, sigErr := src.GetSIGMETs(ctx, MaxTries)
+	airmets, airErr := src.GetAIRMETs(ctx, MaxTries)
+	gairmets, gErr := src.GetGAIRMETs(ctx, MaxTries)
+	if sigErr != nil && airErr != nil && gErr != nil {
+		return nil, errors.Join(sigErr, airErr, gErr)
 	}
+	hazards := parse.BuildHazards(sigmets, airmets, gairmets, station, radiusNM, time.Now())
+	return hazards, errors.Join(sigErr, airErr, gErr)
+}
 
-	cachedWX.Name = APImetar.Name
-	cachedWX.LastUpdateEpoch = time.Now().Unix()
-	cachedWX.METAR.Reported.Epoch = int64(APImetar.ObsTime)
+// updateWindsAloft refreshes the forecast for the station's FB site, searching
+// for the nearest site on the first run
+func updateWindsAloft(ctx context.Context, src fetch.Source, station geo.Point, current types.WindsAloft) (types.WindsAloft, error) {
+	text, err := src.GetWindsAloft(ctx, AloftForecast)
+	if err != nil {
+		return current, err
+	}
+	sites, err := parse.ParseWindsAloft(text)
+	if err != nil {
+		return current, err
+	}
 
-	return cache.Write(cachedWX)
+	if current.Station != "" {
+		if site, ok := sites[current.Station]; ok {
+			site.Lat, site.Lon, site.DistanceNM = current.Lat, current.Lon, current.DistanceNM
+			return site, nil
+		}
+	}
+
+	for _, radius := range aloftSearchNM {
+		stations, err := src.GetStations(ctx, geo.BBox(station, radius), MaxTries)
+		if err != nil {
+			return current, err
+		}
+		if site, ok := parse.NearestFBSite(sites, stations, station); ok {
+			slog.Info("FB site resolved", "site", site.Station)
+			return site, nil
+		}
+	}
+	return current, fmt.Errorf("no FB site within %.0f NM", aloftSearchNM[len(aloftSearchNM)-1])
 }
 
-func resolveAirport() (string, error) {
-	icao, err := cache.ReadICAO()
+// resolveAirport picks the station when --airport isn't given: the active
+// cached station, then the config airport, then KCGI
+func resolveAirport(cfg *config.Config) (string, error) {
+	icao, err := cache.Active()
 	if err == nil && icao != "" {
 		return icao, nil
 	}
+	if cfg.Airport != "" {
+		return strings.ToUpper(cfg.Airport), nil
+	}
 	slog.Warn("no cached or provided airport. using default: KCGI")
 	return "KCGI", nil
 }
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/internal/fetch"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+const testMETAR = "KCGI 191353Z 18005KT 10SM CLR 20/10 A3000"
+
+// fakeSource serves handlers keyed by path, and 404 for anything else
+func fakeSource(t *testing.T, handlers map[string]http.HandlerFunc) fetch.Source {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := handlers[r.URL.Path]; ok {
+			h(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return fetch.Source{BaseURL: srv.URL}
+}
+
+func metarHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, `[{"icaoId":"KCGI","name":"Cape Girardeau","rawOb":%q,"obsTime":%d,"wdir":180,"wspd":5,"visib":10,"lat":37.23,"lon":-89.57,"elev":104}]`,
+		testMETAR, time.Now().Unix())
+}
+
+func tafHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, `[{"icaoId":"KCGI","rawTAF":"TAF KCGI 191130Z 1912/2012 18008KT P6SM SKC"}]`)
+}
+
+func afdHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "FXUS63 KPAH 191130\nAFDPAH\n\n.SYNOPSIS...\nHigh pressure holds.\n&&\n$$\n")
+}
+
+// testUpdate points the cache and config at temp dirs and seeds a located
+// KCGI entry whose CWA is known, so only the modules under test are due
+func testUpdate(t *testing.T, modules config.ModuleCfg) (Flags, *config.Config) {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	cfg := config.Load()
+	cfg.Modules = modules
+	seed := types.Airport{
+		ICAO:   "KCGI",
+		Lat:    37.23,
+		Lon:    -89.57,
+		CWA:    "PAH",
+		RawTAF: "TAF KCGI OLD",
+		Products: map[string]types.ProductState{
+			types.ProductStation: {Expires: time.Now().Add(time.Hour).Unix()},
+		},
+	}
+	if err := cache.Write(seed); err != nil {
+		t.Fatal(err)
+	}
+
+	icao, force, verbose := "KCGI", false, false
+	return Flags{Airport: &icao, Update: &force, Verbose: &verbose}, cfg
+}
+
+func TestRecordExpiry(t *testing.T) {
+	const now = 1_760_000_000
+	d := &UpdateData{now: now, intervals: map[string]int{
+		types.ProductMETAR: 300,
+		types.ProductAFD:   30,
+	}}
+	var wx types.Airport
+
+	d.record(&wx, types.ProductMETAR, nil)
+	if got := wx.Products[types.ProductMETAR]; got.Expires != now+300 || got.FetchedAt != now || got.LastError != "" {
+		t.Errorf("METAR success: %+v, want expiry after its interval", got)
+	}
+
+	// a failure retries after ErrorRetry and keeps the last good fetch
+	d.now = now + 300
+	d.record(&wx, types.ProductMETAR, fmt.Errorf("timeout"))
+	got := wx.Products[types.ProductMETAR]
+	if got.Expires != now+300+ErrorRetry || got.FetchedAt != now || got.CheckedAt != now+300 || got.LastError != "timeout" {
+		t.Errorf("METAR failure: %+v, want retry after %ds", got, ErrorRetry)
+	}
+
+	// but never later than the product's own interval
+	d.record(&wx, types.ProductAFD, fmt.Errorf("503"))
+	if got := wx.Products[types.ProductAFD]; got.Expires != now+300+30 {
+		t.Errorf("AFD failure expires %d, want after its 30s interval", got.Expires-d.now)
+	}
+}
+
+func TestDue(t *testing.T) {
+	// 1452Z is inside the routine window
+	now := time.Date(2026, 10, 19, 14, 52, 0, 0, time.UTC).Unix()
+	fresh := types.ProductState{CheckedAt: now - 30, Expires: now + 600}
+	expired := types.ProductState{CheckedAt: now - 600, Expires: now - 1}
+	thisHour := types.METAR{Reported: types.Timestamp{Epoch: now - 60}}
+	lastHour := types.METAR{Reported: types.Timestamp{Epoch: now - 3600}}
+	all := config.ModuleCfg{METAR: true, TAF: true}
+
+	tests := []struct {
+		name    string
+		product string
+		state   *types.ProductState
+		metar   types.METAR
+		force   bool
+		modules config.ModuleCfg
+		want    bool
+	}{
+		{"never fetched", types.ProductTAF, nil, thisHour, false, all, true},
+		{"fresh", types.ProductTAF, &fresh, thisHour, false, all, false},
+		{"expired", types.ProductTAF, &expired, thisHour, false, all, true},
+		{"forced", types.ProductTAF, &fresh, thisHour, true, all, true},
+		{"disabled", types.ProductTAF, &expired, thisHour, true, config.ModuleCfg{METAR: true}, false},
+		{"has this hour's METAR", types.ProductMETAR, &fresh, thisHour, false, all, false},
+		{"awaiting routine, polled recently", types.ProductMETAR, &fresh, lastHour, false, all, false},
+		{"awaiting routine", types.ProductMETAR, &types.ProductState{CheckedAt: now - RoutinePoll, Expires: now + 600}, lastHour, false, all, true},
+	}
+	for _, tt := range tests {
+		d := &UpdateData{now: now, force: tt.force, modules: tt.modules}
+		d.cached.METAR = tt.metar
+		if tt.state != nil {
+			d.cached.Products = map[string]types.ProductState{tt.product: *tt.state}
+		}
+		if got := d.Due(tt.product); got != tt.want {
+			t.Errorf("%s: Due(%s) = %v, want %v", tt.name, tt.product, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateSetsEachProductsExpiry(t *testing.T) {
+	flags, cfg := testUpdate(t, config.ModuleCfg{METAR: true, TAF: true, AFD: true})
+	var hits atomic.Int32
+	count := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { hits.Add(1); h(w, r) }
+	}
+	src := fakeSource(t, map[string]http.HandlerFunc{
+		"/metar":    count(metarHandler),
+		"/taf":      count(tafHandler),
+		"/fcstdisc": count(afdHandler),
+	})
+
+	if err := Update(context.Background(), src, flags, cfg); err != nil {
+		t.Fatal(err)
+	}
+	wx, err := cache.ReadStation("KCGI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{types.ProductMETAR, types.ProductTAF, types.ProductAFD} {
+		state := wx.Products[p]
+		if state.LastError != "" || state.FetchedAt == 0 {
+			t.Errorf("%s not fetched: %+v", p, state)
+			continue
+		}
+		if want := state.CheckedAt + int64(cfg.Intervals[p]); state.Expires != want {
+			t.Errorf("%s expires %d, want %d (interval %ds)", p, state.Expires, want, cfg.Intervals[p])
+		}
+	}
+	if wx.Elevation != 341 {
+		t.Errorf("elevation = %d ft, want 341 (104 m)", wx.Elevation)
+	}
+
+	// nothing has expired, so the next run fetches nothing
+	before := hits.Load()
+	if err := Update(context.Background(), src, flags, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if after := hits.Load(); after != before {
+		t.Errorf("second update made %d requests, want none", after-before)
+	}
+}
+
+func TestUpdateFetchesConcurrently(t *testing.T) {
+	flags, cfg := testUpdate(t, config.ModuleCfg{METAR: true, TAF: true, AFD: true})
+
+	// the METAR and AFD each wait for the other to arrive, so they only
+	// succeed when fetched side by side
+	metarIn, afdIn := make(chan struct{}), make(chan struct{})
+	rendezvous := func(mine, theirs chan struct{}, h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			close(mine)
+			select {
+			case <-theirs:
+				h(w, r)
+			case <-time.After(5 * time.Second):
+				http.Error(w, "fetched alone", http.StatusBadRequest)
+			}
+		}
+	}
+	src := fakeSource(t, map[string]http.HandlerFunc{
+		"/metar":    rendezvous(metarIn, afdIn, metarHandler),
+		"/fcstdisc": rendezvous(afdIn, metarIn, afdHandler),
+		"/taf": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		},
+	})
+
+	// one product failing doesn't fail the update
+	if err := Update(context.Background(), src, flags, cfg); err != nil {
+		t.Fatal(err)
+	}
+	wx, err := cache.ReadStation("KCGI")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wx.METAR.RawOb != testMETAR || wx.Products[types.ProductMETAR].LastError != "" {
+		t.Errorf("METAR = %q, state %+v", wx.METAR.RawOb, wx.Products[types.ProductMETAR])
+	}
+	if wx.RawAFD == "" || wx.Products[types.ProductAFD].LastError != "" {
+		t.Errorf("AFD missing, state %+v", wx.Products[types.ProductAFD])
+	}
+
+	taf := wx.Products[types.ProductTAF]
+	if taf.LastError == "" || taf.FetchedAt != 0 {
+		t.Errorf("TAF state %+v, want a recorded failure", taf)
+	}
+	if want := taf.CheckedAt + ErrorRetry; taf.Expires != want {
+		t.Errorf("TAF retries at %d, want %d", taf.Expires, want)
+	}
+	if wx.RawTAF != "TAF KCGI OLD" {
+		t.Errorf("failed TAF replaced the old one with %q", wx.RawTAF)
+	}
+	if wx.LastUpdateEpoch == 0 {
+		t.Error("partial update didn't stamp LastUpdateEpoch")
+	}
+}
+
+func TestUpdateWithoutLocation(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	cfg := config.Load()
+	cfg.Modules = config.ModuleCfg{METAR: true, TAF: true}
+	icao, force, verbose := "KXYZ", false, false
+	flags := Flags{Airport: &icao, Update: &force, Verbose: &verbose}
+
+	var tafHits atomic.Int32
+	src := fakeSource(t, map[string]http.HandlerFunc{
+		"/metar": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+		},
+		"/taf": func(w http.ResponseWriter, r *http.Request) { tafHits.Add(1); tafHandler(w, r) },
+	})
+
+	// the TAF waits on the METAR for a location, and never goes out
+	if err := Update(context.Background(), src, flags, cfg); err == nil {
+		t.Fatal("want an error when the airport can't be located")
+	}
+	if n := tafHits.Load(); n != 0 {
+		t.Errorf("TAF fetched %d times without a location", n)
+	}
+	wx, err := cache.ReadStation("KXYZ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wx.Products[types.ProductMETAR].LastError == "" {
+		t.Errorf("METAR failure not recorded: %+v", wx.Products)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// palette holds a colour per flight category plus warning and alert colours
+type palette struct {
+	VFR, MVFR, IFR, LIFR string
+	Warn, Alert          string
+}
+
+var palettes = map[string]palette{
+	// the usual sectional-chart colours
+	"standard": {VFR: "#2ecc40", MVFR: "#0074d9", IFR: "#ff4136", LIFR: "#f012be", Warn: "#ffb000", Alert: "#ff4136"},
+	// Okabe & Ito, safe for the common colour-vision deficiencies
+	"okabe-ito": {VFR: "#009e73", MVFR: "#56b4e9", IFR: "#d55e00", LIFR: "#cc79a7", Warn: "#e69f00", Alert: "#d55e00"},
+	// Paul Tol's bright scheme, also colour-blind safe
+	"tol": {VFR: "#228833", MVFR: "#4477aa", IFR: "#ee6677", LIFR: "#aa3377", Warn: "#ccbb44", Alert: "#ee6677"},
+}
+
+func paletteNamed(name string) palette {
+	if p, ok := palettes[strings.ToLower(name)]; ok {
+		return p
+	}
+	return palettes["standard"]
+}
+
+func (p palette) category(cat string) string {
+	switch cat {
+	case "VFR":
+		return p.VFR
+	case "MVFR":
+		return p.MVFR
+	case "IFR":
+		return p.IFR
+	case "LIFR":
+		return p.LIFR
+	}
+	return ""
+}
+
+// painter wraps values in Pango spans per the colours config; it leaves
+// text alone when colours are off
+type painter struct {
+	cfg config.ColorCfg
+	pal palette
+}
+
+func newPainter(cfg config.ColorCfg) painter {
+	return painter{cfg: cfg, pal: paletteNamed(cfg.Palette)}
+}
+
+func (p painter) span(color, s string) string {
+	if !p.cfg.Enabled || color == "" || s == "" {
+		return s
+	}
+	return fmt.Sprintf(`<span foreground="%s">%s</span>`, color, s)
+}
+
+func (p painter) category(cat, s string) string {
+	return p.span(p.pal.category(cat), s)
+}
+
+func (p painter) gust(k types.Knots, s string) string {
+	if int(k) < p.cfg.GustKnots {
+		return s
+	}
+	return p.span(p.pal.Warn, s)
+}
+
+func (p painter) vis(v types.Mi, s string) string {
+	if float64(v) >= p.cfg.VisBelow {
+		return s
+	}
+	return p.span(p.pal.Alert, s)
+}
+
+func (p painter) alert(s string) string {
+	return p.span(p.pal.Alert, s)
+}
+
+var (
+	markupTag    = regexp.MustCompile(`<[^>]*>`)
+	markupEntity = regexp.MustCompile(`&[#a-zA-Z0-9]+;`)
+)
+
+// visibleLen counts the characters markup leaves on screen
+func visibleLen(s string) int {
+	return len([]rune(markupEntity.ReplaceAllString(markupTag.ReplaceAllString(s, ""), "&")))
+}
+
+// printCSS writes a Waybar style.css snippet colouring the module's classes
+// with the palette
+func printCSS(selector, name string) {
+	name = strings.ToLower(name)
+	if _, ok := palettes[name]; !ok {
+		name = "standard"
+	}
+	p := palettes[name]
+	fmt.Printf("/* pilot-bar, %s palette */\n", name)
+	for _, row := range []struct{ class, rule string }{
+		{"vfr", "color: " + p.VFR},
+		{"mvfr", "color: " + p.MVFR},
+		{"ifr", "color: " + p.IFR},
+		{"lifr", "color: " + p.LIFR},
+		{"hazard", "border-bottom: 2px solid " + p.Alert},
+		{"substitute", "font-style: italic"},
+		{"stale", "opacity: 0.6"},
+		{"expired", "opacity: 0.4"},
+		{"error", "color: " + p.Warn},
+	} {
+		fmt.Printf("%s.%s {\n    %s;\n}\n", selector, row.class, row.rule)
+	}
+}
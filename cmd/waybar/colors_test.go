@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+func TestPaletteNamed(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"standard", "#ff4136"},
+		{"Okabe-Ito", "#d55e00"},
+		{"tol", "#ee6677"},
+		{"", "#ff4136"},
+		{"sepia", "#ff4136"},
+	}
+	for _, tt := range tests {
+		if got := paletteNamed(tt.name).category("IFR"); got != tt.want {
+			t.Errorf("paletteNamed(%q) IFR = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if got := paletteNamed("standard").category("UNKN"); got != "" {
+		t.Errorf("unknown category coloured %q", got)
+	}
+}
+
+func TestPainter(t *testing.T) {
+	defaults := config.Load().Colors
+	on := defaults
+	on.Enabled = true
+	custom := config.ColorCfg{Enabled: true, Palette: "tol", GustKnots: 30, VisBelow: 1}
+
+	tests := []struct {
+		name string
+		cfg  config.ColorCfg
+		got  func(painter) string
+		want string
+	}{
+		{"default off", defaults, func(p painter) string { return p.category("IFR", "IFR") }, "IFR"},
+		{"off ignores alerts", defaults, func(p painter) string { return p.alert("TS") }, "TS"},
+		{"off ignores low vis", defaults, func(p painter) string { return p.vis(0.5, "1/2SM") }, "1/2SM"},
+
+		{"default category", on, func(p painter) string { return p.category("MVFR", "MVFR") },
+			`<span foreground="#0074d9">MVFR</span>`},
+		{"default alert", on, func(p painter) string { return p.alert("TS") }, `<span foreground="#ff4136">TS</span>`},
+		{"default any gust", on, func(p painter) string { return p.gust(types.Knots(15), "G15") }, `<span foreground="#ffb000">G15</span>`},
+		{"default vis below 3", on, func(p painter) string { return p.vis(2, "2SM") }, `<span foreground="#ff4136">2SM</span>`},
+		{"default vis at 3", on, func(p painter) string { return p.vis(3, "3SM") }, "3SM"},
+		{"empty text unpainted", on, func(p painter) string { return p.alert("") }, ""},
+		{"no category unpainted", on, func(p painter) string { return p.category("", "x") }, "x"},
+
+		{"palette override", custom, func(p painter) string { return p.category("LIFR", "LIFR") },
+			`<span foreground="#aa3377">LIFR</span>`},
+		{"gust below override", custom, func(p painter) string { return p.gust(types.Knots(25), "G25") }, "G25"},
+		{"gust at override", custom, func(p painter) string { return p.gust(types.Knots(30), "G30") }, `<span foreground="#ccbb44">G30</span>`},
+		{"vis above override", custom, func(p painter) string { return p.vis(2, "2SM") }, "2SM"},
+		{"vis below override", custom, func(p painter) string { return p.vis(0.5, "1/2SM") }, `<span foreground="#ee6677">1/2SM</span>`},
+	}
+	for _, tt := range tests {
+		if got := tt.got(newPainter(tt.cfg)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVisibleLen(t *testing.T) {
+	tests := map[string]int{
+		"2SM":                                   3,
+		`<span foreground="#ff4136">2SM</span>`: 3,
+		"a &amp; b":                             5,
+		"<b>KCGI</b> <tt>&lt;1SM</tt>":          9,
+		"":                                      0,
+	}
+	for in, want := range tests {
+		if got := visibleLen(in); got != want {
+			t.Errorf("visibleLen(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
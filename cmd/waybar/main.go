@@ -2,48 +2,105 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/house-holder/pilot-bar/internal/cache"
 	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/internal/parse"
 	"github.com/house-holder/pilot-bar/pkg/types"
 	"github.com/spf13/pflag"
 )
 
 type WaybarOutput struct {
-	Text    string `json:"text"`
-	Tooltip string `json:"tooltip"`
-	Class   string `json:"class"`
-	Alt     string `json:"alt"`
+	Text    string   `json:"text"`
+	Tooltip string   `json:"tooltip"`
+	Class   []string `json:"class"`
+	Alt     string   `json:"alt"`
 }
 
 func main() {
 	format := pflag.StringP("format", "f", "", "override config format string")
+	selector := pflag.String("selector", "#custom-pilot-bar", "css: the module's Waybar selector")
 	pflag.Parse()
 
 	cfg := config.Load()
+	if args := pflag.Args(); len(args) > 0 && args[0] == "css" {
+		name := cfg.Colors.Palette
+		if len(args) > 1 {
+			name = args[1]
+		}
+		printCSS(*selector, name)
+		return
+	}
+
 	barFormat := cfg.Format
 	if pflag.Lookup("format").Changed {
 		barFormat = *format
 	}
 
 	wx, err := cache.Read()
+	if errors.Is(err, cache.ErrCorrupt) {
+		// the daemon moves it aside and rebuilds under the cache lock on its
+		// next cycle; the bar only reads, so it can't race a good write
+		json.NewEncoder(os.Stdout).Encode(WaybarOutput{
+			Text:    "\u26A0 wx",
+			Tooltip: "Weather cache is unreadable.\nIt will be rebuilt on the next update.",
+			Class:   []string{"error"},
+		})
+		return
+	}
 	if err != nil {
 		os.Exit(0)
 	}
 
+	age := obsAge(wx.METAR, time.Now())
+	freshness := checkFreshness(age, cfg.Stale)
+
+	tooltip := formatTooltip(wx, cfg)
+	if warning := ageWarning(age, freshness); warning != "" {
+		tooltip = warning + "\n\n" + tooltip
+	}
+
+	list := classes(wx)
+	if class := freshness.class(); class != "" {
+		list = append(list, class)
+	}
+
 	out := WaybarOutput{
-		Text:    formatText(wx, barFormat),
-		Tooltip: formatTooltip(wx),
-		Class:   strings.ToLower(wx.METAR.FltCat),
+		Text:    applyFreshness(formatText(wx, barFormat, age, newPainter(cfg.Colors)), freshness, cfg.Stale),
+		Tooltip: tooltip,
+		Class:   list,
 		Alt:     wx.METAR.FltCat,
 	}
 
 	json.NewEncoder(os.Stdout).Encode(out)
 }
 
+// classes sets the flight category, "substitute" for borrowed METARs, and
+// "hazard" plus one "hazard-<kind>" per active hazard, e.g. "hazard-mtn-obsc"
+func classes(wx types.Airport) []string {
+	list := []string{strings.ToLower(wx.METAR.FltCat)}
+	if wx.METARSub != nil {
+		list = append(list, "substitute")
+	}
+	if len(wx.Hazards) > 0 {
+		list = append(list, "hazard")
+	}
+	for _, h := range wx.Hazards {
+		class := "hazard-" + strings.ReplaceAll(strings.ToLower(h.Kind), " ", "-")
+		if !slices.Contains(list, class) {
+			list = append(list, class)
+		}
+	}
+	return list
+}
+
 const (
 	visUnlimited = 99.0
 	visThreshold = 6.0
@@ -56,35 +113,170 @@ var cloudIcons = map[string]string{
 	"OVC": "\U000F0AA5", // 󰪥
 }
 
-func convertTemps(ambient, dewpoint float64) (float64, float64) {
-	return (ambient*9.0/5.0 + 32), (dewpoint*9.0/5.0 + 32)
+func formatText(wx types.Airport, format string, age time.Duration, paint painter) string {
+	nodes, err := parseTemplate(format)
+	if err != nil {
+		return "format: " + err.Error()
+	}
+	result := textRenderer(wx, age, paint).execute(nodes)
+	if wx.METARSub != nil {
+		result = strings.TrimSpace(fmtSub(wx.METARSub) + " " + result)
+	}
+	return result
 }
 
-func formatText(wx types.Airport, format string) string {
+// textRenderer supplies the bar tokens for wx, coloured by paint
+func textRenderer(wx types.Airport, age time.Duration, paint painter) renderer {
 	m := wx.METAR
 	icon, alt, hasCeiling := ceiling(m.Clouds)
-	ambF, dewF := convertTemps(m.Temp.AmbientExact, m.Temp.DewpointExact)
-
-	replacer := strings.NewReplacer(
-		"{temps}", fmt.Sprintf("%.1f/%.1f", ambF, dewF),
-		"{temp}", fmt.Sprintf("%.1f", ambF),
-		"{dewpoint}", fmt.Sprintf("%.1f", dewF),
-		"{winds}", fmtWind(m.Wind),
-		"{cloud-icon}", fmtIf(hasCeiling, icon),
-		"{clouds}", fmtIf(hasCeiling, fmt.Sprintf("%03d", alt)),
-		"{vis}", fmtVis(m.Visibility),
-		"{wx}", m.WxString,
-		"{stationID}", wx.ICAO,
-		"{age}", fmt.Sprintf("%d", m.Reported.Age),
-		"{fltcat}", m.FltCat,
-		"{altimeter}", fmt.Sprintf("%.2f", float64(m.Altimeter)),
-	)
-
-	result := replacer.Replace(format)
-	for strings.Contains(result, "  ") {
-		result = strings.ReplaceAll(result, "  ", " ")
-	}
-	return strings.TrimSpace(result)
+
+	var kinds []string
+	for _, h := range wx.Hazards {
+		if k := normalizeValue(h.Kind); !slices.Contains(kinds, k) {
+			kinds = append(kinds, k)
+		}
+	}
+
+	tokens := map[string]token{
+		"temps": func(_ string, md mods) string {
+			return temperature(m.Temp.AmbientExact, md) + "/" + temperature(m.Temp.DewpointExact, md)
+		},
+		"temp": func(_ string, md mods) string {
+			return temperature(m.Temp.AmbientExact, md)
+		},
+		"dewpoint": func(_ string, md mods) string {
+			return temperature(m.Temp.DewpointExact, md)
+		},
+		"winds": func(_ string, md mods) string {
+			return fmtWind(m.Wind, md, paint)
+		},
+		"wind-dir": func(string, mods) string {
+			switch {
+			case m.Wind.Calm:
+				return ""
+			case m.Wind.Variable:
+				return "VRB"
+			}
+			return fmt.Sprintf("%03d", m.Wind.Direction)
+		},
+		"wind-speed": func(_ string, md mods) string {
+			return fmtIf(!m.Wind.Calm, speed(m.Wind.Speed, md))
+		},
+		"gust": func(_ string, md mods) string {
+			if m.Wind.Gusts == nil {
+				return ""
+			}
+			return paint.gust(*m.Wind.Gusts, speed(*m.Wind.Gusts, md))
+		},
+		"cloud-icon": func(string, mods) string {
+			return fmtIf(hasCeiling, icon)
+		},
+		"clouds": func(_ string, md mods) string {
+			return fmtIf(hasCeiling, height(alt, md))
+		},
+		"vis": func(_ string, md mods) string {
+			return paint.vis(m.Visibility, fmtVis(m.Visibility, md))
+		},
+		"wx": func(string, mods) string {
+			return m.WxString
+		},
+		"stationID": func(string, mods) string {
+			return wx.ICAO
+		},
+		"age": func(string, mods) string {
+			return fmt.Sprintf("%d", int(age.Minutes()))
+		},
+		"fltcat": func(string, mods) string {
+			return paint.category(m.FltCat, m.FltCat)
+		},
+		"altimeter": func(_ string, md mods) string {
+			return pressure(m.Altimeter, md)
+		},
+		"pireps": func(string, mods) string {
+			return fmtIf(len(wx.PIREPs) > 0, fmt.Sprintf("%d", len(wx.PIREPs)))
+		},
+		"hazards": func(string, mods) string {
+			return paint.alert(strings.Join(kinds, ","))
+		},
+		"aloft": func(arg string, _ mods) string {
+			alt, err := strconv.Atoi(arg)
+			if err != nil {
+				return ""
+			}
+			return fmtAloft(wx.WindsAloft, types.Feet(alt))
+		},
+	}
+	return renderer{tokens: tokens, lists: map[string][]string{"hazards": kinds}}
+}
+
+// temperature renders celsius in Fahrenheit, or Celsius with the C unit
+func temperature(c float64, md mods) string {
+	v := c*9.0/5.0 + 32
+	if md.unit == "c" {
+		v = c
+	}
+	return strconv.FormatFloat(v, 'f', md.precision(1), 64)
+}
+
+var speedUnits = map[string]float64{
+	"kt":  1,
+	"mph": 1.15078,
+	"kmh": 1.852,
+	"mps": 0.514444,
+}
+
+// speed renders knots, or mph, kmh or mps by unit
+func speed(k types.Knots, md mods) string {
+	factor, ok := speedUnits[md.unit]
+	if !ok {
+		factor = 1
+	}
+	return strconv.FormatFloat(float64(k)*factor, 'f', md.precision(0), 64)
+}
+
+// height renders a ceiling in hundreds of feet, e.g. "025", or in whole
+// feet or metres with the ft or m unit
+func height(hundreds int, md mods) string {
+	switch md.unit {
+	case "ft":
+		return strconv.Itoa(hundreds * 100)
+	case "m":
+		return strconv.FormatFloat(float64(hundreds)*30.48, 'f', md.precision(0), 64)
+	}
+	return fmt.Sprintf("%03d", hundreds)
+}
+
+// pressure renders inches of mercury, or hectopascals with hPa or mb
+func pressure(alt types.InHg, md mods) string {
+	if md.unit == "hpa" || md.unit == "mb" {
+		return strconv.FormatFloat(float64(alt)/inHgPerHPa, 'f', md.precision(0), 64)
+	}
+	return strconv.FormatFloat(float64(alt), 'f', md.precision(2), 64)
+}
+
+const inHgPerHPa = 0.02953
+
+// fmtAloft renders interpolated wind and temperature at alt, e.g. "250/35 -12"
+func fmtAloft(w types.WindsAloft, alt types.Feet) string {
+	level, ok := parse.InterpolateAloft(w, alt)
+	if !ok {
+		return ""
+	}
+	var s string
+	if level.Wind.Variable {
+		s = "LV"
+	} else {
+		s = fmt.Sprintf("%03d/%d", level.Wind.Direction, level.Wind.Speed)
+	}
+	if level.Temp != nil {
+		s += fmt.Sprintf(" %+d", *level.Temp)
+	}
+	return s
+}
+
+// fmtSub labels data borrowed from a nearby station, e.g. "[KCGI 12NM]"
+func fmtSub(sub *types.Substitute) string {
+	return fmt.Sprintf("[%s %.0fNM]", sub.ICAO, sub.DistanceNM)
 }
 
 func fmtIf(ok bool, val string) string {
@@ -94,27 +286,38 @@ func fmtIf(ok bool, val string) string {
 	return ""
 }
 
-func fmtWind(w types.WindData) string {
+func fmtWind(w types.WindData, md mods, paint painter) string {
 	if w.Calm {
 		return ""
 	}
 	var s string
 	if w.Variable {
-		s = fmt.Sprintf("VRB %d", w.Speed)
+		s = "VRB " + speed(w.Speed, md)
 	} else {
-		s = fmt.Sprintf("%03d/%d", w.Direction, w.Speed)
+		s = fmt.Sprintf("%03d/%s", w.Direction, speed(w.Speed, md))
 	}
 	if w.Gusts != nil {
-		s += fmt.Sprintf("G%d", *w.Gusts)
+		s += paint.gust(*w.Gusts, "G"+speed(*w.Gusts, md))
 	}
 	return s
 }
 
-func fmtVis(vis types.Mi) string {
+// fmtVis shows visibility below 6SM only, in statute miles or, with the km
+// or m unit, metric
+func fmtVis(vis types.Mi, md mods) string {
 	v := float64(vis)
 	if v <= 0 || v >= visThreshold {
 		return ""
 	}
+	switch md.unit {
+	case "km":
+		return strconv.FormatFloat(v*1.609344, 'f', md.precision(1), 64) + "km"
+	case "m":
+		return strconv.FormatFloat(v*1609.344, 'f', md.precision(0), 64) + "m"
+	}
+	if md.hasPrec {
+		return strconv.FormatFloat(v, 'f', md.prec, 64) + "SM"
+	}
 	return fmt.Sprintf("%gSM", v)
 }
 
@@ -134,25 +337,3 @@ func ceiling(clouds []types.CloudData) (icon string, alt int, ok bool) {
 	}
 	return "", 0, false
 }
-
-func formatTooltip(wx types.Airport) string {
-	var b strings.Builder
-	fmt.Fprintf(&b, "<tt>%s</tt>", wx.METAR.RawOb)
-	if wx.RawTAF != "" {
-		fmt.Fprintf(&b, "\n\n<tt>%s</tt>", wrapTAF(wx.RawTAF))
-	}
-	if wx.RawAFD != "" {
-		fmt.Fprintf(&b, "\n\n%s", wx.RawAFD)
-	}
-	return b.String()
-}
-
-func wrapTAF(raw string) string {
-	r := strings.NewReplacer(
-		" FM", "\n  FM",
-		" TEMPO", "\n  TEMPO",
-		" BECMG", "\n  BECMG",
-		" PROB", "\n  PROB",
-	)
-	return r.Replace(raw)
-}
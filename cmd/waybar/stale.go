@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+type freshness int
+
+const (
+	fresh freshness = iota
+	stale
+	expired
+)
+
+func (f freshness) class() string {
+	switch f {
+	case stale:
+		return "stale"
+	case expired:
+		return "expired"
+	default:
+		return ""
+	}
+}
+
+// obsAge is the observation's age right now, rather than when it was parsed.
+// Entries seeded before the first fetch have no epoch and fall back to the
+// cached age.
+func obsAge(m types.METAR, now time.Time) time.Duration {
+	if m.Reported.Epoch == 0 {
+		return time.Duration(m.Reported.Age) * time.Minute
+	}
+	return now.Sub(time.Unix(m.Reported.Epoch, 0))
+}
+
+func checkFreshness(age time.Duration, cfg config.StaleCfg) freshness {
+	switch {
+	case cfg.ExpiredMinutes > 0 && age >= time.Duration(cfg.ExpiredMinutes)*time.Minute:
+		return expired
+	case cfg.StaleMinutes > 0 && age >= time.Duration(cfg.StaleMinutes)*time.Minute:
+		return stale
+	default:
+		return fresh
+	}
+}
+
+// applyFreshness dims or hides the bar text per config once data goes stale
+func applyFreshness(text string, f freshness, cfg config.StaleCfg) string {
+	if f == expired && cfg.HideExpired {
+		return ""
+	}
+	if f != fresh && cfg.Dim {
+		return fmt.Sprintf(`<span alpha="50%%">%s</span>`, text)
+	}
+	return text
+}
+
+func ageWarning(age time.Duration, f freshness) string {
+	if f == fresh {
+		return ""
+	}
+	return fmt.Sprintf("⚠ Observation is %s old (%s)", fmtAge(age), f.class())
+}
+
+func fmtAge(age time.Duration) string {
+	mins := int(age.Minutes())
+	if mins < 60 {
+		return fmt.Sprintf("%d min", mins)
+	}
+	return fmt.Sprintf("%dh%02dm", mins/60, mins%60)
+}
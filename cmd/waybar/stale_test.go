@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+func TestCheckFreshness(t *testing.T) {
+	cfg := config.StaleCfg{StaleMinutes: 75, ExpiredMinutes: 150}
+	tests := []struct {
+		age   time.Duration
+		cfg   config.StaleCfg
+		want  freshness
+		class string
+	}{
+		{0, cfg, fresh, ""},
+		{75*time.Minute - time.Second, cfg, fresh, ""},
+		{75 * time.Minute, cfg, stale, "stale"},
+		{150*time.Minute - time.Second, cfg, stale, "stale"},
+		{150 * time.Minute, cfg, expired, "expired"},
+		{24 * time.Hour, cfg, expired, "expired"},
+		{24 * time.Hour, config.StaleCfg{StaleMinutes: 75}, stale, "stale"},
+		{24 * time.Hour, config.StaleCfg{ExpiredMinutes: 150}, expired, "expired"},
+		{100 * time.Minute, config.StaleCfg{ExpiredMinutes: 150}, fresh, ""},
+		{24 * time.Hour, config.StaleCfg{}, fresh, ""},
+	}
+	for _, tt := range tests {
+		got := checkFreshness(tt.age, tt.cfg)
+		if got != tt.want || got.class() != tt.class {
+			t.Errorf("checkFreshness(%v, %+v) = %d (class %q), want %d (class %q)",
+				tt.age, tt.cfg, got, got.class(), tt.want, tt.class)
+		}
+	}
+}
+
+func TestApplyFreshness(t *testing.T) {
+	dim := config.StaleCfg{Dim: true}
+	hide := config.StaleCfg{Dim: true, HideExpired: true}
+	tests := []struct {
+		f    freshness
+		cfg  config.StaleCfg
+		want string
+	}{
+		{fresh, hide, "IFR"},
+		{stale, dim, `<span alpha="50%">IFR</span>`},
+		{expired, dim, `<span alpha="50%">IFR</span>`},
+		{stale, config.StaleCfg{}, "IFR"},
+		{stale, hide, `<span alpha="50%">IFR</span>`},
+		{expired, hide, ""},
+	}
+	for _, tt := range tests {
+		if got := applyFreshness("IFR", tt.f, tt.cfg); got != tt.want {
+			t.Errorf("applyFreshness(%s, %+v) = %q, want %q", tt.f.class(), tt.cfg, got, tt.want)
+		}
+	}
+}
+
+func TestObsAge(t *testing.T) {
+	now := time.Date(2026, 10, 19, 14, 52, 0, 0, time.UTC)
+	m := types.METAR{Reported: types.Timestamp{Epoch: now.Add(-80 * time.Minute).Unix(), Age: 5}}
+	if got := obsAge(m, now); got != 80*time.Minute {
+		t.Errorf("obsAge = %v, want 80m from the epoch", got)
+	}
+	m.Reported.Epoch = 0
+	if got := obsAge(m, now); got != 5*time.Minute {
+		t.Errorf("obsAge without epoch = %v, want the cached 5m", got)
+	}
+
+	if got := ageWarning(80*time.Minute, stale); got != "⚠ Observation is 1h20m old (stale)" {
+		t.Errorf("ageWarning = %q", got)
+	}
+	if got := ageWarning(45*time.Minute, fresh); got != "" {
+		t.Errorf("fresh ageWarning = %q, want none", got)
+	}
+}
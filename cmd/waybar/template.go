@@ -0,0 +1,331 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format strings are plain text with:
+//
+//	{token}               a value, e.g. {temps} or {aloft:9000}
+//	{token|mod|...}       width ("5", "-5" to left-align), precision (".0",
+//	                      "5.1") and unit ("C", "kmh", "km", "hPa", "ft")
+//	[...]                 an optional section, dropped when any token in it
+//	                      is empty
+//	{?cond}...{else}...{/} a conditional block; cond is a token name, which
+//	                      holds when it's non-empty, optionally followed by
+//	                      =A,B or !=A,B, and "!" in front negates it
+//
+// e.g. "{temps} {winds}[ G{gust}] {?fltcat=IFR,LIFR}{fltcat}{/}". A
+// backslash escapes the next character.
+
+type nodeKind int
+
+const (
+	literalNode nodeKind = iota
+	tokenNode
+	sectionNode
+	condNode
+)
+
+type node struct {
+	kind     nodeKind
+	text     string // literal text, or the token's name
+	arg      string // token argument after ":"
+	raw      string // the token as written, kept for unknown names
+	mods     mods
+	cond     condition
+	children []node // section body, or the condition's branch
+	orElse   []node
+}
+
+// mods are a token's modifiers
+type mods struct {
+	width   int
+	left    bool
+	prec    int
+	hasPrec bool
+	unit    string // lower case
+}
+
+// precision is the requested precision, or def when none was given
+func (m mods) precision(def int) int {
+	if m.hasPrec {
+		return m.prec
+	}
+	return def
+}
+
+type condition struct {
+	name   string
+	negate bool
+	op     string // "", "=" or "!="
+	values []string
+}
+
+// token renders one value from its argument and modifiers; "" means empty
+type token func(arg string, m mods) string
+
+var numericMod = regexp.MustCompile(`^(-)?(\d*)(?:\.(\d+))?$`)
+
+func parseTemplate(s string) ([]node, error) {
+	p := &templateParser{s: s}
+	nodes, stop, err := p.nodes()
+	if err != nil {
+		return nil, err
+	}
+	if stop != "" {
+		return nil, fmt.Errorf("unexpected %s", stop)
+	}
+	return nodes, nil
+}
+
+type templateParser struct {
+	s   string
+	pos int
+}
+
+// nodes parses up to the end or a closing "]", "{else}" or "{/}", and
+// returns which one stopped it
+func (p *templateParser) nodes() ([]node, string, error) {
+	var out []node
+	var lit strings.Builder
+	flush := func() {
+		if lit.Len() > 0 {
+			out = append(out, node{kind: literalNode, text: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.s):
+			lit.WriteByte(p.s[p.pos+1])
+			p.pos += 2
+		case c == '[':
+			flush()
+			p.pos++
+			body, stop, err := p.nodes()
+			if err != nil {
+				return nil, "", err
+			}
+			if stop != "]" {
+				return nil, "", errors.New("unclosed [")
+			}
+			out = append(out, node{kind: sectionNode, children: body})
+		case c == ']':
+			flush()
+			p.pos++
+			return out, "]", nil
+		case c == '{':
+			end := strings.IndexByte(p.s[p.pos:], '}')
+			if end < 0 {
+				lit.WriteByte(c)
+				p.pos++
+				continue
+			}
+			tag := p.s[p.pos+1 : p.pos+end]
+			p.pos += end + 1
+			flush()
+
+			switch {
+			case tag == "else" || tag == "/":
+				return out, "{" + tag + "}", nil
+			case strings.HasPrefix(tag, "?"):
+				n := node{kind: condNode, cond: parseCondition(tag[1:])}
+				var stop string
+				var err error
+				if n.children, stop, err = p.nodes(); err != nil {
+					return nil, "", err
+				}
+				if stop == "{else}" {
+					if n.orElse, stop, err = p.nodes(); err != nil {
+						return nil, "", err
+					}
+				}
+				if stop != "{/}" {
+					return nil, "", fmt.Errorf("unclosed {%s}", tag)
+				}
+				out = append(out, n)
+			default:
+				out = append(out, parseToken(tag))
+			}
+		default:
+			lit.WriteByte(c)
+			p.pos++
+		}
+	}
+	flush()
+	return out, "", nil
+}
+
+func parseToken(tag string) node {
+	n := node{kind: tokenNode, raw: "{" + tag + "}"}
+	parts := strings.Split(tag, "|")
+	n.text, n.arg, _ = strings.Cut(parts[0], ":")
+	for _, mod := range parts[1:] {
+		if m := numericMod.FindStringSubmatch(mod); m != nil && mod != "" && mod != "-" {
+			n.mods.left = m[1] == "-"
+			n.mods.width, _ = strconv.Atoi(m[2])
+			if m[3] != "" {
+				n.mods.prec, _ = strconv.Atoi(m[3])
+				n.mods.hasPrec = true
+			}
+			continue
+		}
+		n.mods.unit = strings.ToLower(mod)
+	}
+	return n
+}
+
+func parseCondition(s string) condition {
+	var c condition
+	s, c.negate = strings.CutPrefix(strings.TrimSpace(s), "!")
+	name, values, found := strings.Cut(s, "!=")
+	if found {
+		c.op = "!="
+	} else if name, values, found = strings.Cut(s, "="); found {
+		c.op = "="
+	}
+	c.name = strings.TrimSpace(name)
+	if found {
+		for _, v := range strings.Split(values, ",") {
+			c.values = append(c.values, normalizeValue(v))
+		}
+	}
+	return c
+}
+
+// normalizeValue compares case-insensitively, with spaces and dashes alike,
+// so "MTN-OBSC" matches a "MTN OBSC" hazard
+func normalizeValue(s string) string {
+	return strings.ReplaceAll(strings.ToUpper(strings.TrimSpace(s)), " ", "-")
+}
+
+// renderer fills templates from tokens; lists give multi-valued tokens,
+// such as the active hazards, for conditions. A markup renderer escapes the
+// template's own text, leaving tokens to escape their values before painting
+type renderer struct {
+	tokens map[string]token
+	lists  map[string][]string
+	markup bool
+}
+
+// textBuilder collapses the spaces an empty token leaves behind, while
+// keeping a padded value's spaces
+type textBuilder struct {
+	b         strings.Builder
+	openSpace bool // ends in a collapsible space
+	empty     bool // some token rendered empty
+}
+
+func (t *textBuilder) literal(s string) {
+	for _, r := range s {
+		if r == ' ' {
+			if t.openSpace {
+				continue
+			}
+			t.openSpace = true
+		} else {
+			t.openSpace = false
+		}
+		t.b.WriteRune(r)
+	}
+}
+
+func (t *textBuilder) value(s string) {
+	if s == "" {
+		return
+	}
+	t.b.WriteString(s)
+	t.openSpace = false
+}
+
+func (r renderer) execute(nodes []node) string {
+	var t textBuilder
+	r.render(nodes, &t)
+	return strings.TrimSpace(t.b.String())
+}
+
+func (r renderer) render(nodes []node, t *textBuilder) {
+	for _, n := range nodes {
+		switch n.kind {
+		case literalNode:
+			t.literal(r.text(n.text))
+		case tokenNode:
+			tok, ok := r.tokens[n.text]
+			if !ok {
+				t.literal(r.text(n.raw)) // unknown tokens stay as written
+				continue
+			}
+			r.writeValue(t, tok(n.arg, n.mods), n.mods)
+		case sectionNode:
+			var sub textBuilder
+			sub.openSpace = t.openSpace
+			r.render(n.children, &sub)
+			if !sub.empty {
+				t.b.WriteString(sub.b.String())
+				t.openSpace = sub.openSpace
+			}
+		case condNode:
+			if r.test(n.cond) {
+				r.render(n.children, t)
+			} else {
+				r.render(n.orElse, t)
+			}
+		}
+	}
+}
+
+func (r renderer) text(s string) string {
+	if r.markup {
+		return html.EscapeString(s)
+	}
+	return s
+}
+
+func (r renderer) writeValue(t *textBuilder, v string, m mods) {
+	if v == "" {
+		t.empty = true
+	}
+	if pad := m.width - visibleLen(v); pad > 0 {
+		if m.left {
+			v += strings.Repeat(" ", pad)
+		} else {
+			v = strings.Repeat(" ", pad) + v
+		}
+	}
+	t.value(v)
+}
+
+func (r renderer) test(c condition) bool {
+	vals, ok := r.lists[c.name]
+	if !ok {
+		if tok, found := r.tokens[c.name]; found {
+			if v := markupTag.ReplaceAllString(tok("", mods{}), ""); v != "" {
+				vals = []string{v}
+			}
+		}
+	}
+
+	holds := len(vals) > 0
+	if c.op != "" {
+		holds = false
+		for _, v := range vals {
+			for _, want := range c.values {
+				if normalizeValue(v) == want {
+					holds = true
+				}
+			}
+		}
+		if c.op == "!=" {
+			holds = !holds
+		}
+	}
+	return holds != c.negate
+}
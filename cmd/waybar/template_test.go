@@ -0,0 +1,168 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testRenderer has fixed tokens; gust and ceil are empty, as when the METAR
+// has no gusts or no ceiling
+func testRenderer() renderer {
+	fixed := func(v string) token { return func(string, mods) string { return v } }
+	return renderer{
+		tokens: map[string]token{
+			"temps":  fixed("20/10"),
+			"winds":  fixed("18005KT"),
+			"gust":   fixed(""),
+			"ceil":   fixed(""),
+			"fltcat": fixed("IFR"),
+			"vis":    fixed(`<span color="red">2SM</span>`),
+			"echo": func(arg string, m mods) string {
+				if m.unit != "" {
+					return arg + " " + m.unit
+				}
+				return arg
+			},
+		},
+		lists: map[string][]string{
+			"hazards": {"IFR", "MTN OBSC"},
+			"none":    nil,
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name, format, want string
+	}{
+		{"plain", "{temps} {winds}", "20/10 18005KT"},
+		{"unknown token kept", "{temps} {nope}", "20/10 {nope}"},
+		{"empty token collapses spaces", "{temps} {gust} {winds}", "20/10 18005KT"},
+		{"empty section dropped", "{winds}[ G{gust}] {temps}", "18005KT 20/10"},
+		{"full section kept", "{winds}[ T{temps}]", "18005KT T20/10"},
+		{"section drops on any empty", "[{temps} {ceil}]end", "end"},
+		{"nested section", "[{temps}[ G{gust}] x]", "20/10 x"},
+		{"token argument", "{echo:9000}", "9000"},
+		{"unit modifier", "{echo:5|KMH}", "5 kmh"},
+
+		{"escaped brackets", `\[{temps}\]`, "[20/10]"},
+		{"escaped braces", `\{temps\}`, "{temps}"},
+		{"escaped backslash", `a\\b`, `a\b`},
+		{"trailing backslash", `a\`, `a\`},
+		{"lone brace", "{temps} {", "20/10 {"},
+
+		{"width pads right", "|{temps|7}|", "|  20/10|"},
+		{"width pads left", "|{temps|-7}|", "|20/10  |"},
+		{"width ignores markup", "|{vis|5}|", `|  <span color="red">2SM</span>|`},
+		{"padding kept around spaces", "{temps|-7} {winds}", "20/10   18005KT"},
+		{"narrower width", "{temps|2}", "20/10"},
+
+		{"condition holds", "{?fltcat}cat {fltcat}{/}", "cat IFR"},
+		{"empty condition", "{?gust}G{gust}{else}no gust{/}", "no gust"},
+		{"negated", "{?!gust}calm{/}", "calm"},
+		{"equals", "{?fltcat=VFR,IFR}low{else}ok{/}", "low"},
+		{"equals case-insensitive", "{?fltcat=ifr}low{/}", "low"},
+		{"not equals", "{?fltcat!=VFR,MVFR}low{else}ok{/}", "low"},
+		{"not equals matches", "{?fltcat!=IFR}ok{else}low{/}", "low"},
+		{"negated not equals", "{?!fltcat!=IFR}low{/}", "low"},
+		{"list member", "{?hazards=MTN-OBSC}mtns{/}", "mtns"},
+		{"empty list", "{?none}x{else}y{/}", "y"},
+
+		{"section in branch", "{?fltcat}[G{gust}]{temps}{else}[{winds}]{/}", "20/10"},
+		{"section in else", "{?gust}x{else}[{winds}] [{ceil}]{/}", "18005KT"},
+		{"condition in section", "[{?fltcat}{temps}{/}]", "20/10"},
+		{"empty token in taken branch drops section", "[x {?fltcat}{gust}{/}]y", "y"},
+		{"nested conditions", "{?fltcat}{?gust}a{else}b{/}{else}c{/}", "b"},
+	}
+	r := testRenderer()
+	for _, tt := range tests {
+		nodes, err := parseTemplate(tt.format)
+		if err != nil {
+			t.Errorf("%s: parse %q: %v", tt.name, tt.format, err)
+			continue
+		}
+		if got := r.execute(nodes); got != tt.want {
+			t.Errorf("%s: %q rendered %q, want %q", tt.name, tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	tests := []struct {
+		format, want string
+	}{
+		{"[{temps}", "unclosed ["},
+		{"{temps}]", "unexpected ]"},
+		{"{temps}{/}", "unexpected {/}"},
+		{"{temps}{else}", "unexpected {else}"},
+		{"{?fltcat}IFR", "unclosed {?fltcat}"},
+		{"{?fltcat}a{else}b", "unclosed {?fltcat}"},
+		{"{?fltcat}[a{/}]", "unclosed ["},
+		{"[{?fltcat}a]{/}", "unclosed {?fltcat}"},
+		{`\[a]`, "unexpected ]"},
+	}
+	for _, tt := range tests {
+		_, err := parseTemplate(tt.format)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: err = %v, want %q", tt.format, err, tt.want)
+		}
+	}
+}
+
+func TestParseToken(t *testing.T) {
+	tests := []struct {
+		tag       string
+		name, arg string
+		want      mods
+	}{
+		{"temps", "temps", "", mods{}},
+		{"aloft:9000", "aloft", "9000", mods{}},
+		{"temps|5", "temps", "", mods{width: 5}},
+		{"temps|-5", "temps", "", mods{width: 5, left: true}},
+		{"temps|.0", "temps", "", mods{hasPrec: true}},
+		{"temps|5.1", "temps", "", mods{width: 5, prec: 1, hasPrec: true}},
+		{"temps|-6.2|C", "temps", "", mods{width: 6, left: true, prec: 2, hasPrec: true, unit: "c"}},
+		{"winds|KMH", "winds", "", mods{unit: "kmh"}},
+		{"aloft:9000|hPa|4", "aloft", "9000", mods{width: 4, unit: "hpa"}},
+		{"temps|-", "temps", "", mods{unit: "-"}},
+	}
+	for _, tt := range tests {
+		n := parseToken(tt.tag)
+		if n.text != tt.name || n.arg != tt.arg || n.mods != tt.want {
+			t.Errorf("parseToken(%q) = %q:%q %+v, want %q:%q %+v",
+				tt.tag, n.text, n.arg, n.mods, tt.name, tt.arg, tt.want)
+		}
+		if n.raw != "{"+tt.tag+"}" {
+			t.Errorf("parseToken(%q) raw = %q", tt.tag, n.raw)
+		}
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		s    string
+		want condition
+	}{
+		{"gust", condition{name: "gust"}},
+		{"!gust", condition{name: "gust", negate: true}},
+		{"fltcat=IFR, lifr", condition{name: "fltcat", op: "=", values: []string{"IFR", "LIFR"}}},
+		{"fltcat!=VFR", condition{name: "fltcat", op: "!=", values: []string{"VFR"}}},
+		{"!hazards=mtn obsc", condition{name: "hazards", negate: true, op: "=", values: []string{"MTN-OBSC"}}},
+	}
+	for _, tt := range tests {
+		got := parseCondition(tt.s)
+		if got.name != tt.want.name || got.negate != tt.want.negate || got.op != tt.want.op ||
+			strings.Join(got.values, ",") != strings.Join(tt.want.values, ",") {
+			t.Errorf("parseCondition(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	if got := (mods{}).precision(1); got != 1 {
+		t.Errorf("default precision = %d, want 1", got)
+	}
+	if got := (mods{hasPrec: true}).precision(1); got != 0 {
+		t.Errorf(".0 precision = %d, want 0", got)
+	}
+}
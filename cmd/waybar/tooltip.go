@@ -0,0 +1,595 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/internal/parse"
+	"github.com/house-holder/pilot-bar/internal/translate"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// default templates for tooltip sections that take one
+var sectionTemplates = map[string]string{
+	"conditions": "{stationID} {fltcat}\n[Wind {winds}  ][Vis {vis}  ][Ceiling {clouds|ft} ft  ]Temp {temp|C|.0}/{dewpoint|C|.0}°C  Altimeter {altimeter}",
+	"taf":        "{change|-12} {cat|-4} {from|-6} {from-local|-9} {wind|-11} {vis|-5} {clouds|-13} {wx}",
+	"hazards":    "{product|-8} {kind|-10}[ {base}-{top}] until {until}",
+}
+
+// most PIREP rows a pireps section lists, nearest first
+const maxPIREPRows = 5
+
+// tooltipBlock is one section of the tooltip. Lines are markup, each one
+// balanced on its own so the block can be cut between or within lines.
+type tooltipBlock struct {
+	title string
+	lines []string
+	mono  bool
+}
+
+func formatTooltip(wx types.Airport, cfg *config.Config) string {
+	paint := newPainter(cfg.Colors)
+	width := cfg.Layout.MaxWidth
+
+	var blocks []tooltipBlock
+	if cfg.Colors.Enabled && wx.METAR.FltCat != "" {
+		blocks = append(blocks, tooltipBlock{lines: []string{
+			fmt.Sprintf("<b>%s</b> %s", wx.ICAO, paint.category(wx.METAR.FltCat, "<b>"+wx.METAR.FltCat+"</b>")),
+		}})
+	}
+	for _, sec := range cfg.Layout.Sections {
+		tmpl := sec.Template
+		if tmpl == "" {
+			tmpl = sectionTemplates[sec.Type]
+		}
+		if b, ok := tooltipSection(sec.Type, tmpl, wx, cfg, paint, width); ok {
+			blocks = append(blocks, b)
+		}
+	}
+	return renderBlocks(blocks, width, cfg.Layout.MaxLines)
+}
+
+// tooltipSection builds one section, or reports false when there's nothing
+// to show for it
+func tooltipSection(kind, tmpl string, wx types.Airport, cfg *config.Config, paint painter, width int) (tooltipBlock, bool) {
+	switch kind {
+	case "metar":
+		if wx.METAR.RawOb == "" {
+			return tooltipBlock{}, false
+		}
+		var b tooltipBlock
+		if wx.METARSub != nil {
+			b.title = html.EscapeString(fmt.Sprintf("METAR from %s, %.0f NM from %s", wx.METARSub.ICAO, wx.METARSub.DistanceNM, wx.ICAO))
+		}
+		if cfg.Tooltip == "plain" {
+			b.lines = escapeLines(wrapLines([]string{translate.METAR(wx.METAR)}, orDefault(width, tooltipWidth), ""))
+		} else {
+			b.mono = true
+			b.lines = escapeLines(wrapLines([]string{wx.METAR.RawOb}, width, "  "))
+		}
+		return b, true
+
+	case "conditions":
+		if wx.METAR.RawOb == "" {
+			return tooltipBlock{}, false
+		}
+		nodes, err := parseTemplate(tmpl)
+		if err != nil {
+			return tooltipBlock{lines: []string{html.EscapeString("conditions template: " + err.Error())}}, true
+		}
+		text := textRenderer(wx, obsAge(wx.METAR, time.Now()), paint).execute(nodes)
+		return tooltipBlock{lines: strings.Split(text, "\n")}, true
+
+	case "taf":
+		return tafTable(wx, tmpl, paint)
+
+	case "taf-raw":
+		if wx.RawTAF == "" {
+			return tooltipBlock{}, false
+		}
+		b := tooltipBlock{mono: true, title: tafSubTitle(wx)}
+		b.lines = escapeLines(wrapLines(strings.Split(wrapTAF(wx.RawTAF), "\n"), width, "    "))
+		return b, true
+
+	case "hazards":
+		if len(wx.Hazards) == 0 {
+			return tooltipBlock{}, false
+		}
+		return hazardRows(wx.Hazards, tmpl, paint), true
+
+	case "pireps":
+		if len(wx.PIREPs) == 0 {
+			return tooltipBlock{}, false
+		}
+		return pirepRows(wx.PIREPs, tmpl, paint), true
+
+	case "aloft":
+		if wx.WindsAloft.Station == "" {
+			return tooltipBlock{}, false
+		}
+		return aloftTable(wx.WindsAloft, cfg.Aloft.Altitudes), true
+
+	case "discussion":
+		return afdSections(wx.AFD, cfg.AFD.Sections, width)
+	}
+	return tooltipBlock{lines: []string{html.EscapeString(fmt.Sprintf("unknown tooltip section %q", kind))}}, true
+}
+
+// renderBlocks joins blocks with blank lines, cutting lines wider than
+// width. Past maxLines the tooltip ends in an ellipsis line.
+func renderBlocks(blocks []tooltipBlock, width, maxLines int) string {
+	var out []string
+	for i, b := range blocks {
+		sep := min(i, 1)
+		var head, body []string
+		if b.title != "" {
+			head = strings.Split(b.title, "\n")
+		}
+		for _, line := range b.lines {
+			body = append(body, truncateMarkup(line, width))
+		}
+
+		cut := false
+		if maxLines > 0 {
+			avail := maxLines - len(out) - sep
+			if i < len(blocks)-1 {
+				avail-- // a later block may need the ellipsis
+			}
+			if len(head)+len(body) > avail {
+				keep := maxLines - len(out) - sep - len(head) - 1
+				if keep < 1 {
+					out = append(out, "…")
+					break
+				}
+				body, cut = body[:min(keep, len(body))], true
+			}
+		}
+
+		if len(body) > 0 && b.mono {
+			body[0] = "<tt>" + body[0]
+			body[len(body)-1] += "</tt>"
+		}
+		if sep > 0 {
+			out = append(out, "")
+		}
+		out = append(out, head...)
+		out = append(out, body...)
+		if cut {
+			out = append(out, "…")
+			break
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+// truncateMarkup cuts a line to width visible characters, closing any tags
+// left open
+func truncateMarkup(line string, width int) string {
+	if width <= 0 || visibleLen(line) <= width {
+		return line
+	}
+	var b strings.Builder
+	var open []string
+	n := 0
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == '<':
+			end := strings.IndexByte(line[i:], '>')
+			if end < 0 {
+				end = len(line) - i - 1
+			}
+			tag := line[i : i+end+1]
+			b.WriteString(tag)
+			if strings.HasPrefix(tag, "</") {
+				if len(open) > 0 {
+					open = open[:len(open)-1]
+				}
+			} else if name := strings.Fields(strings.Trim(tag, "<>/")); len(name) > 0 && !strings.HasSuffix(tag, "/>") {
+				open = append(open, name[0])
+			}
+			i += end + 1
+			continue
+		case line[i] == '&':
+			if end := strings.IndexByte(line[i:], ';'); end > 0 {
+				if n == width-1 {
+					break
+				}
+				b.WriteString(line[i : i+end+1])
+				n++
+				i += end + 1
+				continue
+			}
+		}
+		if n == width-1 {
+			break
+		}
+		r := []rune(line[i:])[0]
+		b.WriteRune(r)
+		n++
+		i += len(string(r))
+	}
+	b.WriteString("…")
+	for j := len(open) - 1; j >= 0; j-- {
+		b.WriteString("</" + open[j] + ">")
+	}
+	return b.String()
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+func escapeLines(lines []string) []string {
+	for i, l := range lines {
+		lines[i] = html.EscapeString(l)
+	}
+	return lines
+}
+
+// wrapLines wraps each plain-text line to width, indenting continuations;
+// width 0 leaves them alone
+func wrapLines(lines []string, width int, indent string) []string {
+	if width <= 0 {
+		return lines
+	}
+	var out []string
+	for _, line := range lines {
+		lead := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		wrapped := strings.Split(wrap(line, width-len(lead)), "\n")
+		for i, w := range wrapped {
+			if i == 0 {
+				out = append(out, lead+w)
+			} else {
+				out = append(out, lead+indent+w)
+			}
+		}
+	}
+	return out
+}
+
+func tafSubTitle(wx types.Airport) string {
+	if wx.TAFSub == nil {
+		return ""
+	}
+	return html.EscapeString(fmt.Sprintf("TAF from %s, %.0f NM from %s", wx.TAFSub.ICAO, wx.TAFSub.DistanceNM, wx.ICAO))
+}
+
+// tafTable decodes the TAF into a header row and one row per period
+func tafTable(wx types.Airport, tmpl string, paint painter) (tooltipBlock, bool) {
+	if wx.RawTAF == "" {
+		return tooltipBlock{}, false
+	}
+	nodes, err := parseTemplate(tmpl)
+	if err != nil {
+		return tooltipBlock{lines: []string{html.EscapeString("taf template: " + err.Error())}}, true
+	}
+	taf, err := parse.DecodeTAF(wx.RawTAF, time.Now())
+	if err != nil {
+		return tooltipBlock{mono: true, title: tafSubTitle(wx), lines: escapeLines(strings.Split(wrapTAF(wx.RawTAF), "\n"))}, true
+	}
+
+	title := fmt.Sprintf("TAF %s valid %s - %s", taf.Station,
+		time.Unix(taf.ValidFrom, 0).UTC().Format("02/15Z"), time.Unix(taf.ValidTo, 0).UTC().Format("02/15Z"))
+	if sub := tafSubTitle(wx); sub != "" {
+		title = sub + "\n" + title
+	}
+	b := tooltipBlock{mono: true, title: html.EscapeString(title)}
+	b.lines = append(b.lines, headerRenderer(nodes).execute(nodes))
+	for _, g := range taf.Groups {
+		b.lines = append(b.lines, tafRenderer(g, paint).execute(nodes))
+	}
+	return b, true
+}
+
+// headerRenderer names each token in nodes, for a table's header row
+func headerRenderer(nodes []node) renderer {
+	tokens := map[string]token{}
+	var collect func([]node)
+	collect = func(ns []node) {
+		for _, n := range ns {
+			name := n.text
+			if n.kind == tokenNode {
+				tokens[name] = func(string, mods) string {
+					return html.EscapeString(strings.ToUpper(name))
+				}
+			}
+			collect(n.children)
+			collect(n.orElse)
+		}
+	}
+	collect(nodes)
+	return renderer{tokens: tokens, markup: true}
+}
+
+func tafRenderer(g types.TAFGroup, paint painter) renderer {
+	cat := ""
+	if g.Visibility > 0 && len(g.Clouds) > 0 {
+		cat = parse.FlightCategory(g.Visibility, g.Clouds)
+	}
+	zulu := func(epoch int64) string { return time.Unix(epoch, 0).UTC().Format("1504Z") }
+	local := func(epoch int64) string { return time.Unix(epoch, 0).Local().Format("Mon 1504") }
+
+	tokens := map[string]token{
+		"change":     func(string, mods) string { return html.EscapeString(g.Change) },
+		"cat":        func(string, mods) string { return paint.category(cat, cat) },
+		"from":       func(string, mods) string { return zulu(g.From) },
+		"to":         func(string, mods) string { return zulu(g.To) },
+		"from-local": func(string, mods) string { return local(g.From) },
+		"to-local":   func(string, mods) string { return local(g.To) },
+		"wind": func(_ string, md mods) string {
+			if g.Wind == nil {
+				return ""
+			}
+			if g.Wind.Calm {
+				return "calm"
+			}
+			return fmtWind(*g.Wind, md, paint)
+		},
+		"vis": func(_ string, md mods) string {
+			if g.Visibility >= visUnlimited {
+				return "P6SM"
+			}
+			if g.Visibility <= 0 {
+				return ""
+			}
+			s := strconv.FormatFloat(float64(g.Visibility), 'f', md.precision(-1), 64) + "SM"
+			return paint.vis(g.Visibility, s)
+		},
+		"wx":    func(string, mods) string { return html.EscapeString(g.WxString) },
+		"shear": func(string, mods) string { return html.EscapeString(g.WindShear) },
+		"clouds": func(string, mods) string {
+			var parts []string
+			for _, l := range g.Clouds {
+				if l.Base == 0 && !slices.Contains([]string{"FEW", "SCT", "BKN", "OVC", "VV"}, l.Coverage) {
+					parts = append(parts, l.Coverage)
+				} else {
+					parts = append(parts, fmt.Sprintf("%s%03d", l.Coverage, l.Base/100))
+				}
+			}
+			return html.EscapeString(strings.Join(parts, " "))
+		},
+	}
+	return renderer{tokens: tokens, lists: map[string][]string{}, markup: true}
+}
+
+func hazardRows(hazards []types.Hazard, tmpl string, paint painter) tooltipBlock {
+	b := tooltipBlock{mono: true, title: paint.alert("Hazards:")}
+	nodes, err := parseTemplate(tmpl)
+	if err != nil {
+		b.lines = []string{html.EscapeString("hazards template: " + err.Error())}
+		return b
+	}
+	for _, h := range hazards {
+		hundreds := func(f *types.Feet) string {
+			if f == nil {
+				return ""
+			}
+			return fmt.Sprintf("%03d", *f/100)
+		}
+		r := renderer{tokens: map[string]token{
+			"product":  func(string, mods) string { return h.Product },
+			"kind":     func(string, mods) string { return h.Kind },
+			"detail":   func(string, mods) string { return h.Detail },
+			"severity": func(string, mods) string { return h.Severity },
+			"base":     func(string, mods) string { return hundreds(h.Base) },
+			"top":      func(string, mods) string { return hundreds(h.Top) },
+			"from":     func(string, mods) string { return time.Unix(h.ValidFrom, 0).UTC().Format("1504Z") },
+			"until":    func(string, mods) string { return time.Unix(h.ValidTo, 0).UTC().Format("1504Z") },
+		}}
+		b.lines = append(b.lines, "  "+html.EscapeString(r.execute(nodes)))
+	}
+	return b
+}
+
+// altitude bands for PIREP summaries, upper bounds in feet
+var pirepBands = []struct {
+	label string
+	top   types.Feet
+}{
+	{"<FL100", 10000},
+	{"FL100-180", 18000},
+	{">FL180", 1 << 30},
+}
+
+// pirepRows counts icing and turbulence by altitude band, then lists the
+// nearest reports by tmpl when one is set
+func pirepRows(reps []types.PIREP, tmpl string, paint painter) tooltipBlock {
+	var icing, turb [3]int
+	for _, p := range reps {
+		for _, c := range p.Icing {
+			if c.Reported() {
+				icing[pirepBand(p, c)]++
+			}
+		}
+		for _, c := range p.Turbulence {
+			if c.Reported() {
+				turb[pirepBand(p, c)]++
+			}
+		}
+	}
+
+	b := tooltipBlock{mono: true, title: fmt.Sprintf("PIREPs: %d", len(reps))}
+	for _, row := range []struct {
+		label  string
+		counts [3]int
+	}{{"ICE ", icing}, {"TURB", turb}} {
+		line := "  " + row.label
+		for i, band := range pirepBands {
+			line += fmt.Sprintf("  %s: %d", band.label, row.counts[i])
+		}
+		b.lines = append(b.lines, html.EscapeString(line))
+	}
+	if tmpl == "" {
+		return b
+	}
+
+	nodes, err := parseTemplate(tmpl)
+	if err != nil {
+		b.lines = append(b.lines, html.EscapeString("pireps template: "+err.Error()))
+		return b
+	}
+	nearest := slices.Clone(reps)
+	slices.SortFunc(nearest, func(a, b types.PIREP) int {
+		switch {
+		case a.DistanceNM < b.DistanceNM:
+			return -1
+		case a.DistanceNM > b.DistanceNM:
+			return 1
+		}
+		return 0
+	})
+	for _, p := range nearest[:min(len(nearest), maxPIREPRows)] {
+		b.lines = append(b.lines, "  "+html.EscapeString(pirepRenderer(p, paint).execute(nodes)))
+	}
+	return b
+}
+
+func pirepRenderer(p types.PIREP, paint painter) renderer {
+	conditions := func(cs []types.PIREPCondition) string {
+		var parts []string
+		for _, c := range cs {
+			if c.Reported() {
+				parts = append(parts, strings.TrimSpace(c.Intensity+" "+c.Type))
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+	tokens := map[string]token{
+		"time":     func(string, mods) string { return fmtIf(p.Time != "", p.Time+"Z") },
+		"location": func(string, mods) string { return p.Location },
+		"distance": func(string, mods) string { return fmt.Sprintf("%.0f", p.DistanceNM) },
+		"altitude": func(_ string, md mods) string {
+			if p.Altitude == nil {
+				return ""
+			}
+			return height(int(*p.Altitude)/100, md)
+		},
+		"aircraft": func(string, mods) string { return p.AircraftType },
+		"sky":      func(string, mods) string { return p.Sky },
+		"wx":       func(string, mods) string { return p.Weather },
+		"temp": func(string, mods) string {
+			if p.Temp == nil {
+				return ""
+			}
+			return strconv.Itoa(*p.Temp)
+		},
+		"turb":  func(string, mods) string { return conditions(p.Turbulence) },
+		"icing": func(string, mods) string { return conditions(p.Icing) },
+		"urgent": func(string, mods) string {
+			return fmtIf(p.Urgent, "UUA")
+		},
+	}
+	return renderer{tokens: tokens}
+}
+
+// pirepBand places a condition by its reported base, falling back to the
+// aircraft's altitude
+func pirepBand(p types.PIREP, c types.PIREPCondition) int {
+	var alt types.Feet
+	switch {
+	case c.Base != nil:
+		alt = *c.Base
+	case c.Top != nil:
+		alt = *c.Top
+	case p.Altitude != nil:
+		alt = *p.Altitude
+	}
+	for i, band := range pirepBands {
+		if alt < band.top {
+			return i
+		}
+	}
+	return len(pirepBands) - 1
+}
+
+func aloftTable(w types.WindsAloft, altitudes []int) tooltipBlock {
+	b := tooltipBlock{mono: true}
+	b.title = html.EscapeString(fmt.Sprintf("Winds aloft: %s (%.0f NM) valid %s", w.Station, w.DistanceNM, w.Valid))
+	for _, alt := range altitudes {
+		if s := fmtAloft(w, types.Feet(alt)); s != "" {
+			b.lines = append(b.lines, html.EscapeString(fmt.Sprintf("  %5d  %s", alt, s)))
+		}
+	}
+	return b
+}
+
+// afdSections renders the configured discussion sections in config order,
+// reflowing paragraphs to width when one is set
+func afdSections(afd types.AFD, names []string, width int) (tooltipBlock, bool) {
+	var lines []string
+	for _, name := range names {
+		sec, ok := afd.Section(strings.ToUpper(name))
+		if !ok || sec.Body == "" {
+			continue
+		}
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, "<b>"+html.EscapeString(sec.Title)+"</b>")
+		body := strings.Split(sec.Body, "\n")
+		if width > 0 {
+			body = reflow(sec.Body, width)
+		}
+		lines = append(lines, escapeLines(body)...)
+	}
+	if len(lines) == 0 {
+		return tooltipBlock{}, false
+	}
+
+	header := afd.Issued
+	if afd.Forecaster != "" {
+		header += " - " + afd.Forecaster
+	}
+	return tooltipBlock{title: "<i>" + html.EscapeString(header) + "</i>", lines: lines}, true
+}
+
+// reflow rewraps blank-line separated paragraphs to width
+func reflow(text string, width int) []string {
+	var out []string
+	for i, para := range strings.Split(text, "\n\n") {
+		if i > 0 {
+			out = append(out, "")
+		}
+		out = append(out, strings.Split(wrap(para, width), "\n")...)
+	}
+	return out
+}
+
+const tooltipWidth = 60
+
+// wrap breaks prose onto lines of at most width characters
+func wrap(s string, width int) string {
+	var b strings.Builder
+	line := 0
+	for _, word := range strings.Fields(s) {
+		n := len([]rune(word))
+		if line > 0 && line+1+n > width {
+			b.WriteString("\n")
+			line = 0
+		} else if line > 0 {
+			b.WriteString(" ")
+			line++
+		}
+		b.WriteString(word)
+		line += n
+	}
+	return b.String()
+}
+
+func wrapTAF(raw string) string {
+	r := strings.NewReplacer(
+		" FM", "\n  FM",
+		" TEMPO", "\n  TEMPO",
+		" BECMG", "\n  BECMG",
+		" PROB", "\n  PROB",
+	)
+	return r.Replace(raw)
+}
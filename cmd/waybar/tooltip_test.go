@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+const testTAF = "TAF KCGI 191130Z 1912/2012 18008G25KT 2SM BR BKN005 FM191800 20010KT P6SM SKC"
+
+func TestRenderBlocks(t *testing.T) {
+	three := tooltipBlock{lines: []string{"a1", "a2", "a3"}}
+	one := tooltipBlock{lines: []string{"b1"}}
+	tests := []struct {
+		name            string
+		blocks          []tooltipBlock
+		width, maxLines int
+		want            string
+	}{
+		{"no limits", []tooltipBlock{three, one}, 0, 0, "a1\na2\na3\n\nb1"},
+		{"exactly fits", []tooltipBlock{three, one}, 0, 5, "a1\na2\na3\n\nb1"},
+		{"cut within a block", []tooltipBlock{three, one}, 0, 3, "a1\na2\n…"},
+		{"cut between blocks", []tooltipBlock{three, one}, 0, 4, "a1\na2\na3\n…"},
+		{"title counts", []tooltipBlock{{title: "T", lines: []string{"a1", "a2", "a3"}}}, 0, 3, "T\na1\n…"},
+		{"no title without a line", []tooltipBlock{{title: "T", lines: []string{"a1", "a2"}}}, 0, 2, "…"},
+		{"mono wraps body", []tooltipBlock{{mono: true, lines: []string{"x", "y"}}}, 0, 0, "<tt>x\ny</tt>"},
+		{"mono cut closes tt", []tooltipBlock{{mono: true, lines: []string{"a1", "a2", "a3"}}}, 0, 2, "<tt>a1</tt>\n…"},
+		{"long line cut", []tooltipBlock{{title: "Title", lines: []string{"abcdef", "gh"}}}, 4, 0, "Title\nabc…\ngh"},
+		{"cut keeps tags balanced", []tooltipBlock{{lines: []string{`<span foreground="#f00">abcdef</span>`}}}, 4, 0,
+			`<span foreground="#f00">abc…</span>`},
+		{"entity is one column", []tooltipBlock{{lines: []string{"a&amp;bcd"}}}, 4, 0, "a&amp;b…"},
+		{"markup doesn't count", []tooltipBlock{{lines: []string{"<b>abcd</b>"}}}, 4, 0, "<b>abcd</b>"},
+	}
+	for _, tt := range tests {
+		if got := renderBlocks(tt.blocks, tt.width, tt.maxLines); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTooltipSection(t *testing.T) {
+	full := types.Airport{
+		ICAO:       "KCGI",
+		METAR:      types.METAR{RawOb: "KCGI 191353Z 18005KT 10SM CLR 20/10 A3000"},
+		RawTAF:     testTAF,
+		Hazards:    []types.Hazard{{Product: "AIRMET", Kind: "IFR"}},
+		PIREPs:     []types.PIREP{{Location: "CGI"}},
+		WindsAloft: types.WindsAloft{Station: "STL"},
+	}
+	cfg := config.Load()
+	paint := newPainter(cfg.Colors)
+
+	tests := []struct {
+		kind   string
+		always bool // shown even for a bare airport
+		want   string
+	}{
+		{"metar", false, "KCGI 191353Z"},
+		{"taf", false, "TAF KCGI valid"},
+		{"taf-raw", false, "TAF KCGI 191130Z"},
+		{"hazards", false, "AIRMET"},
+		{"pireps", false, "PIREPs: 1"},
+		{"aloft", false, "Winds aloft: STL"},
+		{"discussion", false, ""},
+		{"nonsense", true, `unknown tooltip section &#34;nonsense&#34;`},
+	}
+	for _, tt := range tests {
+		if _, ok := tooltipSection(tt.kind, sectionTemplates[tt.kind], types.Airport{ICAO: "KCGI"}, cfg, paint, 80); ok != tt.always {
+			t.Errorf("%s: shown for an empty airport = %v, want %v", tt.kind, ok, tt.always)
+		}
+		if tt.want == "" {
+			continue
+		}
+		b, ok := tooltipSection(tt.kind, sectionTemplates[tt.kind], full, cfg, paint, 80)
+		if got := b.title + "\n" + strings.Join(b.lines, "\n"); !ok || !strings.Contains(got, tt.want) {
+			t.Errorf("%s: got %q (shown %v), want it to contain %q", tt.kind, got, ok, tt.want)
+		}
+	}
+}
+
+func TestFormatTooltipOrder(t *testing.T) {
+	wx := types.Airport{
+		ICAO:   "KCGI",
+		METAR:  types.METAR{RawOb: "KCGI 191353Z 18005KT 10SM CLR 20/10 A3000"},
+		RawTAF: testTAF,
+	}
+	cfg := config.Load()
+	cfg.Layout.Sections = []config.TooltipSection{{Type: "taf-raw"}, {Type: "pireps"}, {Type: "metar"}}
+
+	got := formatTooltip(wx, cfg)
+	taf, metar := strings.Index(got, "TAF KCGI"), strings.Index(got, "KCGI 191353Z")
+	if taf < 0 || metar < 0 || taf > metar {
+		t.Errorf("sections out of layout order:\n%s", got)
+	}
+	if strings.Contains(got, "PIREPs") {
+		t.Errorf("empty pireps section shown:\n%s", got)
+	}
+}
+
+func TestTAFTableEscapes(t *testing.T) {
+	paint := newPainter(config.ColorCfg{Enabled: true, Palette: "standard", VisBelow: 3, GustKnots: 20})
+	b, ok := tafTable(types.Airport{ICAO: "KCGI", RawTAF: testTAF}, "<{change}> & {vis} {wind} {clouds}", paint)
+	if !ok {
+		t.Fatal("no TAF table")
+	}
+	want := []string{
+		"&lt;CHANGE&gt; &amp; VIS WIND CLOUDS",
+		`&lt;BASE&gt; &amp; <span foreground="#ff4136">2SM</span> 180/8<span foreground="#ffb000">G25</span> BKN005`,
+		"&lt;FM&gt; &amp; P6SM 200/10 SKC",
+	}
+	if strings.Join(b.lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rows:\n%q\nwant:\n%q", b.lines, want)
+	}
+}
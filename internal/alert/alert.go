@@ -0,0 +1,155 @@
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// default look-back for altimeter-drop rules
+const defaultWindow = 60 * time.Minute
+
+// flight categories from worst to best
+var categoryRank = map[string]int{"LIFR": 0, "IFR": 1, "MVFR": 2, "VFR": 3}
+
+type Alert struct {
+	ICAO  string
+	Rule  string // rule name, the dedup key with ICAO
+	Title string
+	Body  string
+	Epoch int64 // observation that triggered it
+}
+
+// Key identifies an alert for cooldown tracking
+func (a Alert) Key() string {
+	return a.ICAO + "/" + a.Rule
+}
+
+// Evaluate checks cur against each rule. A rule fires when cur meets it and
+// prev didn't, so a condition that persists alerts once. history supplies the
+// older observations altimeter-drop looks back over.
+func Evaluate(rules []config.AlertRule, icao string, prev, cur types.Observation, history []types.Observation) []Alert {
+	var alerts []Alert
+	for _, r := range rules {
+		now, detail := check(r, cur, history)
+		if !now {
+			continue
+		}
+		if r.When != "speci" {
+			if before, _ := check(r, prev, history); before {
+				continue
+			}
+		}
+		name := Name(r)
+		alerts = append(alerts, Alert{
+			ICAO:  icao,
+			Rule:  name,
+			Title: fmt.Sprintf("%s: %s", icao, name),
+			Body:  strings.TrimSpace(detail + "\n" + cur.RawOb),
+			Epoch: cur.Epoch,
+		})
+	}
+	return alerts
+}
+
+// Window is the longest history any rule needs
+func Window(rules []config.AlertRule) time.Duration {
+	var longest time.Duration
+	for _, r := range rules {
+		if r.When == "altimeter-drop" {
+			longest = max(longest, window(r))
+		}
+	}
+	return longest
+}
+
+func window(r config.AlertRule) time.Duration {
+	if r.WithinMinutes > 0 {
+		return time.Duration(r.WithinMinutes) * time.Minute
+	}
+	return defaultWindow
+}
+
+// Name is the rule's configured name or a short description of it
+func Name(r config.AlertRule) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	switch r.When {
+	case "category-below":
+		return "below " + strings.ToUpper(r.Category)
+	case "wind":
+		return fmt.Sprintf("wind ≥ %g kt", r.Value)
+	case "gusts":
+		return fmt.Sprintf("gusts ≥ %g kt", r.Value)
+	case "visibility-below":
+		return fmt.Sprintf("visibility < %g SM", r.Value)
+	case "ceiling-below":
+		return fmt.Sprintf("ceiling < %g ft", r.Value)
+	case "wx":
+		return r.Match + " reported"
+	case "altimeter-drop":
+		return fmt.Sprintf("altimeter down %.2f in %d min", r.Value, int(window(r).Minutes()))
+	case "speci":
+		return "SPECI issued"
+	}
+	return r.When
+}
+
+// check reports whether obs meets r, with a line describing what it saw
+func check(r config.AlertRule, obs types.Observation, history []types.Observation) (bool, string) {
+	if obs.Epoch == 0 {
+		return false, ""
+	}
+	switch r.When {
+	case "category-below":
+		have, ok := categoryRank[obs.FltCat]
+		limit, known := categoryRank[strings.ToUpper(r.Category)]
+		return ok && known && have < limit, "Flight category " + obs.FltCat
+	case "wind":
+		return float64(obs.WindSpeed) >= r.Value, fmt.Sprintf("Wind %d kt", obs.WindSpeed)
+	case "gusts":
+		return obs.Gusts > 0 && float64(obs.Gusts) >= r.Value, fmt.Sprintf("Gusts %d kt", obs.Gusts)
+	case "visibility-below":
+		return obs.Visibility > 0 && float64(obs.Visibility) < r.Value, fmt.Sprintf("Visibility %g SM", obs.Visibility)
+	case "ceiling-below":
+		return obs.Ceiling != nil && float64(*obs.Ceiling) < r.Value, ceilingDetail(obs.Ceiling)
+	case "wx":
+		return r.Match != "" && strings.Contains(obs.WxString, r.Match), "Weather " + obs.WxString
+	case "altimeter-drop":
+		return altimeterDrop(r, obs, history)
+	case "speci":
+		return obs.Type == "SPECI", ""
+	}
+	return false, ""
+}
+
+func ceilingDetail(cig *types.Feet) string {
+	if cig == nil {
+		return ""
+	}
+	return fmt.Sprintf("Ceiling %d ft", *cig)
+}
+
+// altimeterDrop compares obs with the highest setting reported in the window
+// before it
+func altimeterDrop(r config.AlertRule, obs types.Observation, history []types.Observation) (bool, string) {
+	if obs.Altimeter <= 0 {
+		return false, ""
+	}
+	from := obs.Epoch - int64(window(r).Seconds())
+	var peak types.InHg
+	for _, h := range history {
+		if h.Epoch >= from && h.Epoch < obs.Epoch && h.Altimeter > peak {
+			peak = h.Altimeter
+		}
+	}
+	drop := float64(peak - obs.Altimeter)
+	if peak == 0 || drop < r.Value {
+		return false, ""
+	}
+	return true, fmt.Sprintf("Altimeter %.2f, down %.2f", float64(obs.Altimeter), drop)
+}
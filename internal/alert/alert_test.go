@@ -0,0 +1,59 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+func TestAltimeterDrop(t *testing.T) {
+	rules := []config.AlertRule{{When: "altimeter-drop", Value: 0.04, WithinMinutes: 60}}
+	const t0 = 1_760_000_000
+	at := func(min int, alt types.InHg) types.Observation {
+		return types.Observation{Epoch: t0 + int64(min)*60, Altimeter: alt}
+	}
+
+	// 30.10 at :00 falling to 30.05 by :50 and 30.04 by :70. At :50 the drop
+	// is already 0.05, so :70 must not fire again; that needs history from
+	// before :10, a window back from prev rather than from cur.
+	history := []types.Observation{at(0, 30.10), at(20, 30.08), at(50, 30.05), at(70, 30.04)}
+
+	tests := []struct {
+		name      string
+		prev, cur types.Observation
+		history   []types.Observation
+		want      int
+	}{
+		{"drop starts", at(20, 30.08), at(50, 30.05), history, 1},
+		{"drop persists", at(50, 30.05), at(70, 30.04), history, 0},
+		{"no history", at(50, 30.05), at(70, 30.04), nil, 0},
+		{"steady", at(0, 30.10), at(20, 30.08), history, 0},
+	}
+	for _, tt := range tests {
+		got := Evaluate(rules, "KCGI", tt.prev, tt.cur, tt.history)
+		if len(got) != tt.want {
+			t.Errorf("%s: %d alerts, want %d: %+v", tt.name, len(got), tt.want, got)
+		}
+	}
+}
+
+func TestEvaluateEdgeTriggered(t *testing.T) {
+	rules := []config.AlertRule{
+		{When: "category-below", Category: "MVFR"},
+		{When: "gusts", Value: 20},
+		{When: "speci"},
+	}
+	vfr := types.Observation{Epoch: 1, FltCat: "VFR", Type: "METAR"}
+	ifr := types.Observation{Epoch: 2, FltCat: "IFR", Type: "METAR", Gusts: 25}
+	ifrSpeci := types.Observation{Epoch: 3, FltCat: "IFR", Type: "SPECI", Gusts: 25}
+
+	if got := Evaluate(rules, "KCGI", vfr, ifr, nil); len(got) != 2 {
+		t.Errorf("VFR to gusty IFR: %d alerts, want 2: %+v", len(got), got)
+	}
+	// the category and gusts persist, but every SPECI alerts
+	got := Evaluate(rules, "KCGI", ifr, ifrSpeci, nil)
+	if len(got) != 1 || got[0].Rule != "SPECI issued" {
+		t.Errorf("IFR to IFR SPECI: %+v, want only the SPECI alert", got)
+	}
+}
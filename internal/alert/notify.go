@@ -0,0 +1,99 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/config"
+)
+
+const notifyTimeout = 10 * time.Second
+
+// Notifier delivers an alert somewhere a person will see it
+type Notifier interface {
+	Notify(a Alert) error
+}
+
+// NotifySend posts to the freedesktop Notifications service via notify-send
+type NotifySend struct {
+	Urgency string // low, normal, critical
+}
+
+func (n NotifySend) Notify(a Alert) error {
+	urgency := n.Urgency
+	if urgency == "" {
+		urgency = "normal"
+	}
+	return run(nil, "notify-send", "--app-name=pilot-bar", "--urgency="+urgency, a.Title, a.Body)
+}
+
+// Command runs a program with the alert in its environment:
+// PILOT_BAR_ALERT_ICAO, _RULE, _TITLE, _BODY and _TIME (epoch seconds)
+type Command struct {
+	Argv []string
+}
+
+func (c Command) Notify(a Alert) error {
+	if len(c.Argv) == 0 {
+		return errors.New("command notifier: no command configured")
+	}
+	env := append(os.Environ(),
+		"PILOT_BAR_ALERT_ICAO="+a.ICAO,
+		"PILOT_BAR_ALERT_RULE="+a.Rule,
+		"PILOT_BAR_ALERT_TITLE="+a.Title,
+		"PILOT_BAR_ALERT_BODY="+a.Body,
+		"PILOT_BAR_ALERT_TIME="+strconv.FormatInt(a.Epoch, 10),
+	)
+	return run(env, c.Argv...)
+}
+
+// run executes argv with a timeout; a nil env inherits ours
+func run(env []string, argv ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", argv[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Notifiers builds the configured notifiers, defaulting to notify-send
+func Notifiers(cfgs []config.NotifierCfg) ([]Notifier, error) {
+	if len(cfgs) == 0 {
+		return []Notifier{NotifySend{}}, nil
+	}
+	var list []Notifier
+	for _, c := range cfgs {
+		switch c.Type {
+		case "notify-send":
+			list = append(list, NotifySend{Urgency: c.Urgency})
+		case "command":
+			list = append(list, Command{Argv: c.Command})
+		default:
+			return nil, fmt.Errorf("unknown notifier type %q", c.Type)
+		}
+	}
+	return list, nil
+}
+
+// Throttle drops alerts that fired within cooldown, per Key, and records the
+// rest in fired (Key to epoch seconds)
+func Throttle(alerts []Alert, fired map[string]int64, cooldown time.Duration, now time.Time) []Alert {
+	var out []Alert
+	for _, a := range alerts {
+		if last, ok := fired[a.Key()]; ok && now.Sub(time.Unix(last, 0)) < cooldown {
+			continue
+		}
+		fired[a.Key()] = now.Unix()
+		out = append(out, a)
+	}
+	return out
+}
@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const alertFile = "alerts.json"
+
+// AlertState returns when each alert last fired, keyed "ICAO/rule", in epoch
+// seconds. A missing or unreadable file starts fresh.
+func (s Store) AlertState() (map[string]int64, error) {
+	d, err := s.dir()
+	if err != nil {
+		return nil, err
+	}
+	fired := make(map[string]int64)
+	data, err := os.ReadFile(filepath.Join(d, alertFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fired, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("alerts: read: %w", err)
+	}
+	if err := json.Unmarshal(data, &fired); err != nil {
+		return make(map[string]int64), nil
+	}
+	return fired, nil
+}
+
+// SetAlertState replaces the alert state; hold Lock around the read and write
+func (s Store) SetAlertState(fired map[string]int64) error {
+	d, err := s.dir()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(fired)
+	if err != nil {
+		return fmt.Errorf("alerts: marshal: %w", err)
+	}
+	if err := os.MkdirAll(d, 0755); err != nil {
+		return fmt.Errorf("alerts: mkdir: %w", err)
+	}
+	return writeAtomic(filepath.Join(d, alertFile), data)
+}
@@ -1,17 +1,57 @@
 package cache
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/house-holder/pilot-bar/pkg/types"
 )
 
-const cacheFile = "currentWX.json"
+const (
+	stationDir = "stations"
+	activeFile = "active"
+	lockFile   = "cache.lock"
 
-func dir() (string, error) {
+	// single-airport cache from before stations/ existed
+	legacyFile = "currentWX.json"
+)
+
+// ErrCorrupt means the cache file exists but can't be decoded
+var ErrCorrupt = errors.New("cache corrupt")
+
+// ErrLocked means TryLock found the cache lock held elsewhere
+var ErrLocked = errors.New("cache locked")
+
+// ErrBadICAO means a station ID isn't safe to use as a file name
+var ErrBadICAO = errors.New("invalid ICAO identifier")
+
+var icaoPattern = regexp.MustCompile(`^[A-Z0-9]{3,4}$`)
+
+// ValidICAO reports whether icao, in any case, is a 3-4 character station ID
+func ValidICAO(icao string) bool {
+	return icaoPattern.MatchString(strings.ToUpper(icao))
+}
+
+// Store is a cache rooted at Dir. The zero Store uses
+// $XDG_CACHE_HOME/pilot-bar.
+type Store struct {
+	Dir string
+}
+
+func (s Store) dir() (string, error) {
+	if s.Dir != "" {
+		return s.Dir, nil
+	}
 	cacheDir := os.Getenv("XDG_CACHE_HOME")
 	if cacheDir == "" {
 		home, err := os.UserHomeDir()
@@ -23,58 +63,296 @@ func dir() (string, error) {
 	return filepath.Join(cacheDir, "pilot-bar"), nil
 }
 
-func Read() (types.Airport, error) {
-	d, err := dir()
+func (s Store) stationPath(icao string) (string, error) {
+	if !ValidICAO(icao) {
+		return "", fmt.Errorf("cache: %w: %q", ErrBadICAO, icao)
+	}
+	d, err := s.dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, stationDir, strings.ToUpper(icao)+".json"), nil
+}
+
+// Read loads the active station's entry
+func (s Store) Read() (types.Airport, error) {
+	icao, err := s.Active()
 	if err != nil {
 		return types.Airport{}, err
 	}
-	data, err := os.ReadFile(filepath.Join(d, cacheFile))
+	return s.ReadStation(icao)
+}
+
+func (s Store) ReadStation(icao string) (types.Airport, error) {
+	p, err := s.stationPath(icao)
 	if err != nil {
 		return types.Airport{}, err
 	}
-	var airport types.Airport
-	if err := json.Unmarshal(data, &airport); err != nil {
-		return types.Airport{}, fmt.Errorf("cache: unmarshal: %w", err)
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return types.Airport{}, err
 	}
-	return airport, nil
+	return decode(data)
 }
 
-func Write(airport types.Airport) error {
-	d, err := dir()
+// Write stores airport under its own ICAO; it doesn't change the active station
+func (s Store) Write(airport types.Airport) error {
+	p, err := s.stationPath(airport.ICAO)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(d, 0755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
 		return fmt.Errorf("cache: mkdir: %w", err)
 	}
-	data, err := json.MarshalIndent(airport, "", "  ")
+	data, err := encode(airport)
 	if err != nil {
 		return fmt.Errorf("cache: marshal: %w", err)
 	}
-	return os.WriteFile(filepath.Join(d, cacheFile), data, 0644)
+	return writeAtomic(p, data)
 }
 
-func ReadICAO() (string, error) {
-	airport, err := Read()
+// Active returns the station the bar displays
+func (s Store) Active() (string, error) {
+	d, err := s.dir()
 	if err != nil {
 		return "", err
 	}
-	return airport.ICAO, nil
+	data, err := os.ReadFile(filepath.Join(d, activeFile))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (s Store) SetActive(icao string) error {
+	if !ValidICAO(icao) {
+		return fmt.Errorf("cache: %w: %q", ErrBadICAO, icao)
+	}
+	d, err := s.dir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d, 0755); err != nil {
+		return fmt.Errorf("cache: mkdir: %w", err)
+	}
+	return writeAtomic(filepath.Join(d, activeFile), []byte(strings.ToUpper(icao)+"\n"))
+}
+
+// Stations lists every cached ICAO, most recently updated first
+func (s Store) Stations() ([]string, error) {
+	d, err := s.dir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(d, stationDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cache: list: %w", err)
+	}
+
+	type station struct {
+		icao    string
+		modTime time.Time
+	}
+	var list []station
+	for _, e := range entries {
+		icao, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, station{icao, info.ModTime()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].modTime.After(list[j].modTime) })
+
+	icaos := make([]string, len(list))
+	for i, s := range list {
+		icaos[i] = s.icao
+	}
+	return icaos, nil
+}
+
+// Prune drops stations not updated within maxAge, then the oldest beyond
+// maxStations. The active station is always kept. Zero disables a limit.
+func (s Store) Prune(maxStations int, maxAge time.Duration) error {
+	icaos, err := s.Stations()
+	if err != nil {
+		return err
+	}
+	active, _ := s.Active()
+
+	kept := 0
+	if slices.Contains(icaos, active) {
+		kept = 1
+	}
+	for _, icao := range icaos {
+		if icao == active {
+			continue
+		}
+		p, err := s.stationPath(icao)
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(p)
+		if err != nil {
+			continue
+		}
+		tooOld := maxAge > 0 && time.Since(info.ModTime()) > maxAge
+		tooMany := maxStations > 0 && kept >= maxStations
+		if tooOld || tooMany {
+			slog.Debug("Pruning cached station", "icao", icao)
+			if err := os.Remove(p); err != nil {
+				return fmt.Errorf("cache: prune: %w", err)
+			}
+			continue
+		}
+		kept++
+	}
+	return nil
 }
 
-func EnsureExists(icao string) error {
-	d, err := dir()
+// writeAtomic writes to a temp file beside path and renames it into place, so
+// readers only ever see the old file or the complete new one
+func writeAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("cache: temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cache: write: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cache: sync: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cache: close: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("cache: chmod: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("cache: rename: %w", err)
+	}
+	return nil
+}
+
+// Lock takes an exclusive advisory lock on the cache directory, blocking until
+// any other holder releases it. Hold it around read-modify-write cycles; Read
+// and Write don't lock on their own.
+func (s Store) Lock() (unlock func(), err error) {
+	return s.lock(syscall.LOCK_EX)
+}
+
+// TryLock is Lock without the wait: it fails with ErrLocked when another
+// holder has the lock
+func (s Store) TryLock() (unlock func(), err error) {
+	return s.lock(syscall.LOCK_EX | syscall.LOCK_NB)
+}
+
+func (s Store) lock(how int) (unlock func(), err error) {
+	d, err := s.dir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(d, 0755); err != nil {
+		return nil, fmt.Errorf("cache: mkdir: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(d, lockFile), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("cache: lock file: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrLocked
+		}
+		return nil, fmt.Errorf("cache: flock: %w", err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// Quarantine moves a corrupt or unmigratable station entry aside so the next
+// update starts clean
+func (s Store) Quarantine(icao string) error {
+	p, err := s.stationPath(icao)
 	if err != nil {
 		return err
 	}
-	p := filepath.Join(d, cacheFile)
-	if _, err := os.Stat(p); os.IsNotExist(err) {
-		return Write(types.Airport{
-			ICAO: icao,
-			METAR: types.METAR{
-				Reported: types.Timestamp{Epoch: 0},
-			},
-		})
+	if err := os.Rename(p, p+".corrupt"); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cache: quarantine: %w", err)
 	}
 	return nil
 }
+
+// EnsureExists seeds an empty entry for icao when it isn't cached yet, or
+// when its entry is corrupt or from a schema we can't migrate
+func (s Store) EnsureExists(icao string) error {
+	_, err := s.ReadStation(icao)
+	if errors.Is(err, ErrCorrupt) {
+		if err := s.Quarantine(icao); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return s.Write(types.Airport{
+		ICAO: strings.ToUpper(icao),
+		METAR: types.METAR{
+			Reported: types.Timestamp{Epoch: 0},
+		},
+	})
+}
+
+// MigrateLegacy moves a single-airport currentWX.json into stations/ and
+// makes it active. It takes the cache lock when there's a file to move, so
+// callers mustn't hold it.
+func (s Store) MigrateLegacy() error {
+	d, err := s.dir()
+	if err != nil {
+		return err
+	}
+	legacy := filepath.Join(d, legacyFile)
+	if _, err := os.Stat(legacy); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	unlock, err := s.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(legacy)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // another process got here first
+	}
+	if err != nil {
+		return err
+	}
+
+	airport, err := decode(data)
+	if err != nil || !ValidICAO(airport.ICAO) {
+		return os.Rename(legacy, legacy+".corrupt")
+	}
+	if err := s.Write(airport); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(d, activeFile)); errors.Is(err, fs.ErrNotExist) {
+		if err := s.SetActive(airport.ICAO); err != nil {
+			return err
+		}
+	}
+	return os.Remove(legacy)
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPathsRejectBadICAO(t *testing.T) {
+	s := Store{Dir: t.TempDir()}
+	paths := map[string]func(string) (string, error){
+		"stationPath": s.stationPath,
+		"historyPath": s.historyPath,
+	}
+
+	for name, path := range paths {
+		for _, icao := range []string{"", "../x", "K/GI", "KCGI.json", "ABCDE", "K", " KCGI"} {
+			if _, err := path(icao); !errors.Is(err, ErrBadICAO) {
+				t.Errorf("%s(%q) err = %v, want ErrBadICAO", name, icao, err)
+			}
+		}
+		for _, icao := range []string{"KCGI", "kcgi", "ORD", "K2W6"} {
+			if _, err := path(icao); err != nil {
+				t.Errorf("%s(%q): %v", name, icao, err)
+			}
+		}
+	}
+	if _, err := s.History("../x", time.Time{}); !errors.Is(err, ErrBadICAO) {
+		t.Errorf("History(../x) err = %v, want ErrBadICAO", err)
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	d := t.TempDir()
+	s := Store{Dir: d}
+
+	if err := s.MigrateLegacy(); err != nil {
+		t.Fatalf("no legacy file: %v", err)
+	}
+	if _, err := s.Active(); err == nil {
+		t.Fatal("active station set without a legacy file")
+	}
+
+	writeFile(t, d, legacyFile, `{"icao":"KCGI","last_update":1700000000}`)
+	if err := s.MigrateLegacy(); err != nil {
+		t.Fatal(err)
+	}
+	if icao, err := s.Active(); err != nil || icao != "KCGI" {
+		t.Fatalf("s.Active() = %q, %v; want KCGI", icao, err)
+	}
+	wx, err := s.ReadStation("KCGI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wx.LastUpdateEpoch != 1700000000 {
+		t.Errorf("LastUpdateEpoch = %d, want 1700000000", wx.LastUpdateEpoch)
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	s := Store{Dir: t.TempDir()}
+	unlock, err := s.Lock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.TryLock(); !errors.Is(err, ErrLocked) {
+		t.Fatalf("TryLock while held: err = %v, want ErrLocked", err)
+	}
+	unlock()
+
+	unlock, err = s.TryLock()
+	if err != nil {
+		t.Fatalf("TryLock once free: %v", err)
+	}
+	unlock()
+}
+
+func writeFile(t *testing.T, dir, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
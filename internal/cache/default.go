@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// The package-level functions act on the default Store, the one the daemon
+// and the bar share.
+var std Store
+
+// Read loads the active station's entry
+func Read() (types.Airport, error) { return std.Read() }
+
+func ReadStation(icao string) (types.Airport, error) { return std.ReadStation(icao) }
+
+// Write stores airport under its own ICAO; it doesn't change the active station
+func Write(airport types.Airport) error { return std.Write(airport) }
+
+// Active returns the station the bar displays
+func Active() (string, error) { return std.Active() }
+
+func SetActive(icao string) error { return std.SetActive(icao) }
+
+// Stations lists every cached ICAO, most recently updated first
+func Stations() ([]string, error) { return std.Stations() }
+
+// Prune drops stations not updated within maxAge, then the oldest beyond
+// maxStations
+func Prune(maxStations int, maxAge time.Duration) error {
+	return std.Prune(maxStations, maxAge)
+}
+
+// Lock takes the default store's cache lock
+func Lock() (unlock func(), err error) { return std.Lock() }
+
+// TryLock takes the default store's cache lock if it's free
+func TryLock() (unlock func(), err error) { return std.TryLock() }
+
+// Quarantine moves a corrupt station entry aside
+func Quarantine(icao string) error { return std.Quarantine(icao) }
+
+// EnsureExists seeds an empty entry for icao when it's missing or corrupt
+func EnsureExists(icao string) error { return std.EnsureExists(icao) }
+
+// MigrateLegacy moves a single-airport currentWX.json into stations/
+func MigrateLegacy() error { return std.MigrateLegacy() }
+
+// AppendHistory adds obs to the station's history
+func AppendHistory(icao string, obs types.Observation, maxAge time.Duration, maxEntries int) error {
+	return std.AppendHistory(icao, obs, maxAge, maxEntries)
+}
+
+// History returns the station's observations since since, oldest first
+func History(icao string, since time.Time) ([]types.Observation, error) {
+	return std.History(icao, since)
+}
+
+// AlertState returns when each alert last fired
+func AlertState() (map[string]int64, error) { return std.AlertState() }
+
+// SetAlertState replaces the alert state; hold Lock around the read and write
+func SetAlertState(fired map[string]int64) error { return std.SetAlertState(fired) }
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+const historyDir = "history"
+
+func (s Store) historyPath(icao string) (string, error) {
+	if !ValidICAO(icao) {
+		return "", fmt.Errorf("history: %w: %q", ErrBadICAO, icao)
+	}
+	d, err := s.dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d, historyDir, strings.ToUpper(icao)+".jsonl"), nil
+}
+
+// AppendHistory adds obs to the station's history, skipping repeats of the
+// newest entry, and trims anything older than maxAge or beyond maxEntries.
+// Zero disables a limit.
+func (s Store) AppendHistory(icao string, obs types.Observation, maxAge time.Duration, maxEntries int) error {
+	entries, err := s.History(icao, time.Time{})
+	if err != nil {
+		return err
+	}
+	if n := len(entries); n > 0 && entries[n-1].Epoch >= obs.Epoch {
+		return nil
+	}
+	entries = append(entries, obs)
+
+	if maxAge > 0 {
+		cutoff := time.Now().Add(-maxAge).Unix()
+		for len(entries) > 0 && entries[0].Epoch < cutoff {
+			entries = entries[1:]
+		}
+	}
+	if maxEntries > 0 && len(entries) > maxEntries {
+		entries = entries[len(entries)-maxEntries:]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("history: marshal: %w", err)
+		}
+	}
+
+	p, err := s.historyPath(icao)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("history: mkdir: %w", err)
+	}
+	return writeAtomic(p, buf.Bytes())
+}
+
+// History returns the station's observations since the given time, oldest
+// first. A station with no history returns an empty list.
+func (s Store) History(icao string, since time.Time) ([]types.Observation, error) {
+	p, err := s.historyPath(icao)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: open: %w", err)
+	}
+	defer f.Close()
+
+	var entries []types.Observation
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var obs types.Observation
+		if err := json.Unmarshal(scanner.Bytes(), &obs); err != nil {
+			continue // a bad line shouldn't cost the rest of the history
+		}
+		if obs.Epoch >= since.Unix() {
+			entries = append(entries, obs)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("history: read: %w", err)
+	}
+	return entries, nil
+}
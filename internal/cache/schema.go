@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// SchemaVersion is bumped whenever a change to types.Airport would misread
+// older cache files; add a migration for the previous version alongside it.
+// Files written before versioning existed count as version 1.
+const SchemaVersion = 1
+
+// ErrSchema means the file is from a version we can't migrate; it wraps
+// ErrCorrupt so callers rebuild it the same way
+var ErrSchema = fmt.Errorf("%w: unsupported schema", ErrCorrupt)
+
+// migrations[n] upgrades a version n document to version n+1 in place
+var migrations = map[int]func(doc map[string]any) error{}
+
+// entry is the on-disk form of a station: the airport plus its schema version
+type entry struct {
+	Version int `json:"schemaVersion"`
+	types.Airport
+}
+
+func encode(airport types.Airport) ([]byte, error) {
+	return json.MarshalIndent(entry{Version: SchemaVersion, Airport: airport}, "", "  ")
+}
+
+// decode reads a station file of any known version, migrating it as needed
+func decode(data []byte) (types.Airport, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return types.Airport{}, fmt.Errorf("cache: unmarshal: %w: %w", ErrCorrupt, err)
+	}
+
+	version := 1
+	if v, ok := doc["schemaVersion"].(float64); ok {
+		version = int(v)
+	}
+	if version > SchemaVersion {
+		return types.Airport{}, fmt.Errorf("cache: version %d is newer than %d: %w",
+			version, SchemaVersion, ErrSchema)
+	}
+
+	if err := migrate(doc, version, SchemaVersion); err != nil {
+		return types.Airport{}, err
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return types.Airport{}, fmt.Errorf("cache: remarshal: %w", err)
+	}
+	var e entry
+	if err := json.Unmarshal(migrated, &e); err != nil {
+		return types.Airport{}, fmt.Errorf("cache: unmarshal: %w: %w", ErrCorrupt, err)
+	}
+	return e.Airport, nil
+}
+
+// migrate runs the migrations taking doc from version up to target
+func migrate(doc map[string]any, version, target int) error {
+	for ; version < target; version++ {
+		step, ok := migrations[version]
+		if !ok {
+			return fmt.Errorf("cache: no migration from version %d: %w", version, ErrSchema)
+		}
+		if err := step(doc); err != nil {
+			return fmt.Errorf("cache: migrate from version %d: %w: %w", version, ErrSchema, err)
+		}
+	}
+	return nil
+}
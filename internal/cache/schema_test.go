@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+func TestDecodeUnversioned(t *testing.T) {
+	wx, err := decode([]byte(`{"icao":"KCGI","last_update":1700000000,"metar":{"rawOb":"KCGI 191853Z AUTO 00000KT 10SM CLR 12/08 A3012"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wx.ICAO != "KCGI" || wx.LastUpdateEpoch != 1700000000 || wx.METAR.RawOb == "" {
+		t.Errorf("decode = %+v", wx)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	data, err := encode(types.Airport{ICAO: "KCGI", LastUpdateEpoch: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wx, err := decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wx.ICAO != "KCGI" || wx.LastUpdateEpoch != 42 {
+		t.Errorf("round trip = %+v", wx)
+	}
+}
+
+func TestDecodeRejectsUnknownVersions(t *testing.T) {
+	for _, v := range []int{0, SchemaVersion + 1, 99} {
+		doc := fmt.Sprintf(`{"schemaVersion":%d,"icao":"KCGI"}`, v)
+		if _, err := decode([]byte(doc)); !errors.Is(err, ErrSchema) || !errors.Is(err, ErrCorrupt) {
+			t.Errorf("version %d: err = %v, want ErrSchema wrapping ErrCorrupt", v, err)
+		}
+	}
+	if _, err := decode([]byte(`{"icao":`)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("truncated file: err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = map[int]func(map[string]any) error{
+		1: func(doc map[string]any) error {
+			doc["name"] = "renamed in v2"
+			return nil
+		},
+		2: func(doc map[string]any) error {
+			return errors.New("boom")
+		},
+	}
+
+	doc := map[string]any{"icao": "KCGI"}
+	if err := migrate(doc, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if doc["name"] != "renamed in v2" {
+		t.Errorf("v1 migration not applied: %v", doc)
+	}
+
+	if err := migrate(map[string]any{}, 1, 3); !errors.Is(err, ErrSchema) {
+		t.Errorf("failing step: err = %v, want ErrSchema", err)
+	}
+	if err := migrate(map[string]any{}, 3, 4); !errors.Is(err, ErrSchema) {
+		t.Errorf("missing step: err = %v, want ErrSchema", err)
+	}
+}
+
+func TestEnsureExistsRebuildsNewerVersion(t *testing.T) {
+	d := t.TempDir()
+	s := Store{Dir: d}
+
+	if err := os.MkdirAll(filepath.Join(d, stationDir), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(d, stationDir), "KCGI.json",
+		fmt.Sprintf(`{"schemaVersion":%d,"icao":"KCGI","last_update":5}`, SchemaVersion+1))
+
+	if err := s.EnsureExists("KCGI"); err != nil {
+		t.Fatal(err)
+	}
+	wx, err := s.ReadStation("KCGI")
+	if err != nil {
+		t.Fatalf("rebuilt entry unreadable: %v", err)
+	}
+	if wx.LastUpdateEpoch != 0 {
+		t.Errorf("rebuilt entry kept old data: %+v", wx)
+	}
+	if _, err := os.Stat(filepath.Join(d, stationDir, "KCGI.json.corrupt")); err != nil {
+		t.Errorf("newer file not quarantined: %v", err)
+	}
+}
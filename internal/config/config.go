@@ -2,14 +2,31 @@ package config
 
 import (
 	"encoding/json"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 )
 
 type Config struct {
-	Airport string    `json:"airport"`
-	Format  string    `json:"format"`
-	Modules ModuleCfg `json:"modules"`
+	Airport string     `json:"airport"`
+	Format  string     `json:"format"`
+	Tooltip string     `json:"tooltip"` // METAR in the tooltip: "raw" or "plain"
+	Layout  TooltipCfg `json:"tooltipLayout"`
+	Modules ModuleCfg  `json:"modules"`
+	Aloft   AloftCfg   `json:"aloft"`
+	AFD     AFDCfg     `json:"discussion"`
+	Hazards HazardCfg  `json:"hazards"`
+	Cache   CacheCfg   `json:"cache"`
+	History HistCfg    `json:"history"`
+	Stale   StaleCfg   `json:"stale"`
+	API     APICfg     `json:"api"`
+	Alerts  AlertCfg   `json:"alerts"`
+	Colors  ColorCfg   `json:"colors"`
+
+	// seconds between refreshes, keyed by product: metar, taf, afd, station,
+	// pirep, hazards, aloft
+	Intervals map[string]int `json:"intervals"`
 }
 
 type ModuleCfg struct {
@@ -18,14 +35,155 @@ type ModuleCfg struct {
 	AFD    bool `json:"discussion"`
 	AIRMET bool `json:"airmet"`
 	PIREP  bool `json:"pirep"`
+	Aloft  bool `json:"aloft"`
+}
+
+type AloftCfg struct {
+	Altitudes []int `json:"altitudes"` // feet, shown in the tooltip table
+}
+
+type AFDCfg struct {
+	// section names shown in the tooltip: SYNOPSIS, NEAR TERM, SHORT TERM,
+	// LONG TERM, AVIATION, WATCHES/WARNINGS...
+	Sections []string `json:"sections"`
+}
+
+type HazardCfg struct {
+	// nautical miles beyond the station an AIRMET/SIGMET area may lie and
+	// still be reported; 0 only reports areas over the station
+	RadiusNM float64 `json:"radiusNM"`
+}
+
+// retention for per-station cache entries, 0 disables a limit
+type CacheCfg struct {
+	MaxStations int `json:"maxStations"`
+	MaxAgeHours int `json:"maxAgeHours"`
+}
+
+// retention for the per-station observation history, 0 disables a limit
+type HistCfg struct {
+	RetentionHours int `json:"retentionHours"`
+	MaxEntries     int `json:"maxEntries"`
+}
+
+// observation age thresholds for the bar, 0 disables a threshold
+type StaleCfg struct {
+	StaleMinutes   int  `json:"staleMinutes"`
+	ExpiredMinutes int  `json:"expiredMinutes"`
+	Dim            bool `json:"dim"`         // fade the text once stale
+	HideExpired    bool `json:"hideExpired"` // blank the text once expired
+}
+
+// local HTTP API served by `run`; off unless Listen is set
+type APICfg struct {
+	Listen string `json:"listen"` // host:port, e.g. "127.0.0.1:8732"
+
+	// web origins allowed to read the API from a browser, e.g.
+	// "http://localhost:3000"; none by default
+	AllowOrigins []string `json:"allowOrigins"`
+}
+
+type AlertCfg struct {
+	CooldownMinutes int           `json:"cooldownMinutes"` // per rule and station
+	Notifiers       []NotifierCfg `json:"notifiers"`       // notify-send when empty
+	Rules           []AlertRule   `json:"rules"`
+}
+
+type NotifierCfg struct {
+	Type    string   `json:"type"`              // notify-send or command
+	Urgency string   `json:"urgency,omitempty"` // notify-send: low, normal, critical
+	Command []string `json:"command,omitempty"` // command: argv, alert passed in PILOT_BAR_ALERT_* env
+}
+
+// AlertRule fires when a new observation meets its condition and the previous
+// one didn't. When picks the condition:
+//
+//	category-below    flight category worse than Category (VFR, MVFR, IFR)
+//	wind, gusts       knots >= Value
+//	visibility-below  statute miles < Value
+//	ceiling-below     feet < Value
+//	wx                weather string contains Match, e.g. "TS"
+//	altimeter-drop    inHg fall >= Value within WithinMinutes (default 60)
+//	speci             any SPECI
+type AlertRule struct {
+	Name          string  `json:"name,omitempty"` // defaults to a description of the rule
+	When          string  `json:"when"`
+	Category      string  `json:"category,omitempty"`
+	Value         float64 `json:"value,omitempty"`
+	Match         string  `json:"match,omitempty"`
+	WithinMinutes int     `json:"withinMinutes,omitempty"`
 }
 
+// TooltipCfg orders the tooltip's sections and bounds its size
+type TooltipCfg struct {
+	Sections []TooltipSection `json:"sections"`
+	MaxWidth int              `json:"maxWidth"` // columns, longer lines wrap or are cut; 0 for no limit
+	MaxLines int              `json:"maxLines"` // 0 for no limit
+}
+
+// TooltipSection is one block of the tooltip. Type is one of:
+//
+//	metar       the METAR, raw or plain per Tooltip
+//	conditions  decoded current conditions from Template, a bar format string
+//	taf         the TAF as a table, one Template row per period
+//	taf-raw     the raw TAF, one line per change group
+//	hazards     active hazards, one Template row each
+//	pireps      PIREP counts by altitude, plus a Template row per nearby report
+//	aloft       winds aloft at the configured altitudes
+//	discussion  the configured forecast discussion sections
+type TooltipSection struct {
+	Type     string `json:"type"`
+	Template string `json:"template,omitempty"` // defaults per type
+}
+
+// Pango colouring of the bar text and tooltip
+type ColorCfg struct {
+	Enabled   bool    `json:"enabled"`
+	Palette   string  `json:"palette"`   // standard, okabe-ito or tol; the last two are colour-blind safe
+	GustKnots int     `json:"gustKnots"` // gusts at or above this are amber, 0 for any gust
+	VisBelow  float64 `json:"visBelow"`  // statute miles, lower visibility is red
+}
+
+var defaultSections = []string{"AVIATION"}
+
+var defaultIntervals = map[string]int{
+	"metar":   300,
+	"taf":     1800,
+	"afd":     3600,
+	"station": 86400,
+	"pirep":   900,
+	"hazards": 900,
+	"aloft":   3600,
+}
+
+var defaultLayout = []TooltipSection{
+	{Type: "metar"},
+	{Type: "taf-raw"},
+	{Type: "hazards"},
+	{Type: "pireps"},
+	{Type: "aloft"},
+	{Type: "discussion"},
+}
+
+var defaultAltitudes = []int{3000, 6000, 9000, 12000}
+
 const defaultFormat = "{temps} {vis} {cloud-icon} {clouds} {wx}"
 
 func Load() *Config {
 	defaults := &Config{
 		Format:  defaultFormat,
+		Tooltip: "raw",
+		Layout:  TooltipCfg{Sections: slices.Clone(defaultLayout), MaxWidth: 80, MaxLines: 40},
 		Modules: ModuleCfg{METAR: true},
+		Aloft:   AloftCfg{Altitudes: defaultAltitudes},
+		AFD:     AFDCfg{Sections: defaultSections},
+		Cache:   CacheCfg{MaxStations: 10, MaxAgeHours: 168},
+		History: HistCfg{RetentionHours: 72, MaxEntries: 1000},
+		Stale:   StaleCfg{StaleMinutes: 75, ExpiredMinutes: 150, Dim: true},
+		Alerts:  AlertCfg{CooldownMinutes: 60},
+		Colors:  ColorCfg{Palette: "standard", VisBelow: 3},
+
+		Intervals: maps.Clone(defaultIntervals),
 	}
 
 	path, err := configPath()
@@ -38,7 +196,15 @@ func Load() *Config {
 		return defaults
 	}
 
-	var cfg Config
+	cfg := Config{
+		Modules: defaults.Modules,
+		Cache:   defaults.Cache,
+		History: defaults.History,
+		Stale:   defaults.Stale,
+		Alerts:  defaults.Alerts,
+		Colors:  defaults.Colors,
+		Layout:  defaults.Layout,
+	}
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return defaults
 	}
@@ -46,6 +212,26 @@ func Load() *Config {
 	if cfg.Format == "" {
 		cfg.Format = defaults.Format
 	}
+	if cfg.Tooltip == "" {
+		cfg.Tooltip = defaults.Tooltip
+	}
+	if len(cfg.Layout.Sections) == 0 {
+		cfg.Layout.Sections = slices.Clone(defaultLayout)
+	}
+	if len(cfg.Aloft.Altitudes) == 0 {
+		cfg.Aloft.Altitudes = defaults.Aloft.Altitudes
+	}
+	if len(cfg.AFD.Sections) == 0 {
+		cfg.AFD.Sections = defaults.AFD.Sections
+	}
+	if cfg.Intervals == nil {
+		cfg.Intervals = make(map[string]int)
+	}
+	for product, seconds := range defaultIntervals {
+		if cfg.Intervals[product] <= 0 {
+			cfg.Intervals[product] = seconds
+		}
+	}
 
 	return &cfg
 }
@@ -0,0 +1,40 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// GetWindsAloft loads the raw FB winds/temps product for every site. fcst is
+// the forecast period in hours: "06", "12" or "24".
+func (s Source) GetWindsAloft(ctx context.Context, fcst string) (string, error) {
+	url := fmt.Sprintf("%s/windtemp?region=all&level=low&fcst=%s", s.base(), fcst)
+	client := s.client()
+
+	resp, err := get(ctx, client, url)
+	if err != nil {
+		return "", fmt.Errorf("FB fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FB fetch status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("FB read failed: %w", err)
+	}
+
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return "", fmt.Errorf("empty FB product")
+	}
+
+	slog.Info("FB OK")
+	return text, nil
+}
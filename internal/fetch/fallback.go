@@ -0,0 +1,73 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/house-holder/pilot-bar/internal/geo"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// search radii for a stand-in station, widened until one reports
+var fallbackSearchNM = []float64{25, 50, 100}
+
+// GetMETARWithFallback falls back to the nearest reporting station when icao
+// has no METAR of its own. sub is nil when the station reported for itself.
+func (s Source) GetMETARWithFallback(ctx context.Context, icao string, maxAttempts int) (types.METARresponse, *types.Substitute, error) {
+	resp, err := s.GetMETAR(ctx, icao, maxAttempts)
+	if !errors.Is(err, ErrNoData) {
+		return resp, nil, err
+	}
+
+	slog.Info("No METAR, searching nearby", "icao", icao)
+	info, err := s.GetAirportInfo(ctx, icao, maxAttempts)
+	if err != nil {
+		return resp, nil, fmt.Errorf("locating %s failed: %w", icao, err)
+	}
+	center := geo.Point{Lat: info.Lat, Lon: info.Lon}
+
+	for _, radius := range fallbackSearchNM {
+		near, dist, err := s.GetNearestMETAR(ctx, center, radius, maxAttempts)
+		if errors.Is(err, ErrNoData) {
+			continue
+		}
+		if err != nil {
+			return resp, nil, err
+		}
+		slog.Info("Substituting METAR", "from", near.IcaoID, "distance", fmt.Sprintf("%.0fNM", dist))
+
+		// keep the requested airport's identity, only the weather is borrowed
+		near.Lat, near.Long, near.Elev = info.Lat, info.Lon, info.Elev
+		if info.Name != "" {
+			near.Name = info.Name
+		}
+		return near, &types.Substitute{ICAO: near.IcaoID, DistanceNM: dist}, nil
+	}
+	return resp, nil, fmt.Errorf("METAR within %.0f NM of %s: %w",
+		fallbackSearchNM[len(fallbackSearchNM)-1], icao, ErrNoData)
+}
+
+// GetTAFWithFallback falls back to the nearest TAF when icao issues none
+func (s Source) GetTAFWithFallback(ctx context.Context, icao string, center geo.Point, maxAttempts int) (types.TAFresponse, *types.Substitute, error) {
+	resp, err := s.GetTAF(ctx, icao, maxAttempts)
+	if !errors.Is(err, ErrNoData) {
+		return resp, nil, err
+	}
+
+	slog.Info("No TAF, searching nearby", "icao", icao)
+	for _, radius := range fallbackSearchNM {
+		near, dist, err := s.GetNearestTAF(ctx, center, radius, maxAttempts)
+		if errors.Is(err, ErrNoData) {
+			continue
+		}
+		if err != nil {
+			return resp, nil, err
+		}
+		slog.Info("Substituting TAF", "from", near.IcaoID, "distance", fmt.Sprintf("%.0fNM", dist))
+		return near, &types.Substitute{ICAO: near.IcaoID, DistanceNM: dist}, nil
+	}
+	return resp, nil, fmt.Errorf("TAF within %.0f NM of %s: %w",
+		fallbackSearchNM[len(fallbackSearchNM)-1], icao, ErrNoData)
+}
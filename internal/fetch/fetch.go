@@ -1,6 +1,7 @@
 package fetch
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,25 +17,28 @@ import (
 
 const baseURL = "https://aviationweather.gov/api/data"
 
+// ErrNoData means the request succeeded but the station has nothing to report
+var ErrNoData = errors.New("no data")
+
 // FetchMETAR loads full report into a default-shaped struct
-func GetMETAR(icao string, maxAttempts int) (types.METARresponse, error) {
+func (s Source) GetMETAR(ctx context.Context, icao string, maxAttempts int) (types.METARresponse, error) {
 	if maxAttempts < 1 {
 		maxAttempts = 1
 	}
 
-	metarURL := fmt.Sprintf("%s/metar?ids=%s&format=json", baseURL, icao)
-	client := &http.Client{Timeout: 10 * time.Second}
+	metarURL := fmt.Sprintf("%s/metar?ids=%s&format=json", s.base(), icao)
+	client := s.client()
 	startTime := time.Now()
 
 	var payload []types.METARresponse
-	err := doWithRetry(maxAttempts, func(attempt int) (bool, error) {
+	err := doWithRetry(ctx, maxAttempts, func(attempt int) (bool, error) {
 		if attempt > 1 {
 			slog.Info(fmt.Sprintf("Fetch METAR retry (%d of %d)", attempt, maxAttempts))
 		} else {
 			slog.Info("Fetching METAR")
 		}
 
-		resp, err := client.Get(metarURL)
+		resp, err := get(ctx, client, metarURL)
 		if err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
@@ -50,6 +54,9 @@ func GetMETAR(icao string, maxAttempts int) (types.METARresponse, error) {
 			return true, fmt.Errorf("status %d: %s", resp.StatusCode, resp.Status)
 		}
 
+		if resp.StatusCode == http.StatusNoContent {
+			return false, fmt.Errorf("METAR for %s: %w", icao, ErrNoData)
+		}
 		if resp.StatusCode != http.StatusOK {
 			return false, fmt.Errorf("status: %s", resp.Status)
 		}
@@ -59,7 +66,7 @@ func GetMETAR(icao string, maxAttempts int) (types.METARresponse, error) {
 			return false, fmt.Errorf("decode failed: %w", err)
 		}
 		if len(decoded) == 0 {
-			return false, fmt.Errorf("no METAR data for %s", icao)
+			return false, fmt.Errorf("METAR for %s: %w", icao, ErrNoData)
 		}
 
 		payload = decoded
@@ -75,24 +82,24 @@ func GetMETAR(icao string, maxAttempts int) (types.METARresponse, error) {
 	return payload[0], nil
 }
 
-func GetTAF(icao string, maxAttempts int) (types.TAFresponse, error) {
+func (s Source) GetTAF(ctx context.Context, icao string, maxAttempts int) (types.TAFresponse, error) {
 	if maxAttempts < 1 {
 		maxAttempts = 1
 	}
 
-	tafURL := fmt.Sprintf("%s/taf?ids=%s&format=json", baseURL, icao)
-	client := &http.Client{Timeout: 10 * time.Second}
+	tafURL := fmt.Sprintf("%s/taf?ids=%s&format=json", s.base(), icao)
+	client := s.client()
 	startTime := time.Now()
 
 	var payload []types.TAFresponse
-	err := doWithRetry(maxAttempts, func(attempt int) (bool, error) {
+	err := doWithRetry(ctx, maxAttempts, func(attempt int) (bool, error) {
 		if attempt > 1 {
 			slog.Info(fmt.Sprintf("Fetch TAF retry (%d of %d)", attempt, maxAttempts))
 		} else {
 			slog.Info("Fetching TAF")
 		}
 
-		resp, err := client.Get(tafURL)
+		resp, err := get(ctx, client, tafURL)
 		if err != nil {
 			var netErr net.Error
 			if errors.As(err, &netErr) && netErr.Timeout() {
@@ -108,6 +115,9 @@ func GetTAF(icao string, maxAttempts int) (types.TAFresponse, error) {
 			return true, fmt.Errorf("status %d: %s", resp.StatusCode, resp.Status)
 		}
 
+		if resp.StatusCode == http.StatusNoContent {
+			return false, fmt.Errorf("TAF for %s: %w", icao, ErrNoData)
+		}
 		if resp.StatusCode != http.StatusOK {
 			return false, fmt.Errorf("status: %s", resp.Status)
 		}
@@ -117,7 +127,7 @@ func GetTAF(icao string, maxAttempts int) (types.TAFresponse, error) {
 			return false, fmt.Errorf("decode failed: %w", err)
 		}
 		if len(decoded) == 0 {
-			return false, fmt.Errorf("no TAF data for %s", icao)
+			return false, fmt.Errorf("TAF for %s: %w", icao, ErrNoData)
 		}
 
 		payload = decoded
@@ -133,11 +143,11 @@ func GetTAF(icao string, maxAttempts int) (types.TAFresponse, error) {
 	return payload[0], nil
 }
 
-func LookupCWA(lat, lon float64) (string, error) {
+func (s Source) LookupCWA(ctx context.Context, lat, lon float64) (string, error) {
 	url := fmt.Sprintf("https://api.weather.gov/points/%.4f,%.4f", lat, lon)
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := s.client()
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -169,12 +179,12 @@ func LookupCWA(lat, lon float64) (string, error) {
 	return result.Properties.CWA, nil
 }
 
-func GetAFD(cwa string) (string, error) {
+func (s Source) GetAFD(ctx context.Context, cwa string) (string, error) {
 	wfo := "k" + strings.ToLower(cwa)
-	url := fmt.Sprintf("%s/fcstdisc?cwa=%s&type=afd", baseURL, wfo)
-	client := &http.Client{Timeout: 10 * time.Second}
+	url := fmt.Sprintf("%s/fcstdisc?cwa=%s&type=afd", s.base(), wfo)
+	client := s.client()
 
-	resp, err := client.Get(url)
+	resp, err := get(ctx, client, url)
 	if err != nil {
 		return "", fmt.Errorf("AFD fetch failed: %w", err)
 	}
@@ -198,7 +208,73 @@ func GetAFD(cwa string) (string, error) {
 	return strings.TrimSuffix(text, "\u0003"), nil
 }
 
-func doWithRetry(maxAttempts int, op func(attempt int) (bool, error)) error {
+// getJSON decodes the response at url into v, retrying on timeouts and
+// retryable statuses. An empty (204) response leaves v untouched.
+func (s Source) getJSON(ctx context.Context, url, product string, maxAttempts int, v any) error {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	client := s.client()
+	startTime := time.Now()
+
+	err := doWithRetry(ctx, maxAttempts, func(attempt int) (bool, error) {
+		if attempt > 1 {
+			slog.Info(fmt.Sprintf("Fetch %s retry (%d of %d)", product, attempt, maxAttempts))
+		} else {
+			slog.Info("Fetching " + product)
+		}
+
+		resp, err := get(ctx, client, url)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				slog.Warn("Fetch timeout", "attempt", attempt, "max", maxAttempts)
+				return true, err
+			}
+			return false, fmt.Errorf("HTTP request failed: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if statusRetryOK(resp.StatusCode) {
+			slog.Warn("OK to retry", "status", resp.Status, "attempt", attempt)
+			return true, fmt.Errorf("status %d: %s", resp.StatusCode, resp.Status)
+		}
+
+		if resp.StatusCode == http.StatusNoContent {
+			return false, nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return false, fmt.Errorf("status: %s", resp.Status)
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			return false, fmt.Errorf("decode failed: %w", err)
+		}
+		return false, nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fetchDuration := time.Since(startTime).Seconds()
+	slog.Info(product+" OK", "took", fmt.Sprintf("%.3fs", fetchDuration))
+	return nil
+}
+
+// get issues a GET that's abandoned when ctx ends
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// doWithRetry runs op until it succeeds, says not to retry, runs out of
+// attempts, or ctx ends
+func doWithRetry(ctx context.Context, maxAttempts int, op func(attempt int) (bool, error)) error {
 	var lastErr error
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		retry, err := op(attempt)
@@ -207,10 +283,14 @@ func doWithRetry(maxAttempts int, op func(attempt int) (bool, error)) error {
 		}
 
 		lastErr = err
-		if !retry || attempt == maxAttempts {
+		if !retry || attempt == maxAttempts || ctx.Err() != nil {
 			break
 		}
-		time.Sleep(2 * time.Second) // backoff delay
+		select {
+		case <-time.After(2 * time.Second): // backoff delay
+		case <-ctx.Done():
+			return errors.Join(lastErr, ctx.Err())
+		}
 	}
 
 	return lastErr
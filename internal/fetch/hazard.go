@@ -0,0 +1,39 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// GetSIGMETs loads all current domestic SIGMETs, convective included
+func (s Source) GetSIGMETs(ctx context.Context, maxAttempts int) ([]types.AirSigmetResponse, error) {
+	return s.getAirSigmets(ctx, "sigmet", maxAttempts)
+}
+
+// GetAIRMETs loads all current text AIRMETs
+func (s Source) GetAIRMETs(ctx context.Context, maxAttempts int) ([]types.AirSigmetResponse, error) {
+	return s.getAirSigmets(ctx, "airmet", maxAttempts)
+}
+
+func (s Source) getAirSigmets(ctx context.Context, kind string, maxAttempts int) ([]types.AirSigmetResponse, error) {
+	url := fmt.Sprintf("%s/airsigmet?format=json&type=%s", s.base(), kind)
+
+	var decoded []types.AirSigmetResponse
+	if err := s.getJSON(ctx, url, kind, maxAttempts, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// GetGAIRMETs loads the current G-AIRMET snapshots for every hazard
+func (s Source) GetGAIRMETs(ctx context.Context, maxAttempts int) ([]types.GAIRMETresponse, error) {
+	url := fmt.Sprintf("%s/gairmet?format=json", s.base())
+
+	var decoded []types.GAIRMETresponse
+	if err := s.getJSON(ctx, url, "G-AIRMET", maxAttempts, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
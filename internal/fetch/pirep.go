@@ -0,0 +1,32 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/house-holder/pilot-bar/internal/geo"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// GetPIREPs loads reports filed within radiusNM of lat/lon in the last ageHours
+func (s Source) GetPIREPs(ctx context.Context, lat, lon, radiusNM float64, ageHours, maxAttempts int) ([]types.PIREPresponse, error) {
+	if ageHours < 1 {
+		ageHours = 1
+	}
+
+	center := geo.Point{Lat: lat, Lon: lon}
+	pirepURL := fmt.Sprintf("%s/pirep?format=json&age=%d&bbox=%s",
+		s.base(), ageHours, geo.BBox(center, radiusNM))
+
+	var decoded []types.PIREPresponse
+	if err := s.getJSON(ctx, pirepURL, "PIREP", maxAttempts, &decoded); err != nil {
+		return nil, err
+	}
+
+	// the bbox is square, trim the corners back to the radius
+	decoded = slices.DeleteFunc(decoded, func(p types.PIREPresponse) bool {
+		return geo.DistanceNM(center, geo.Point{Lat: p.Lat, Lon: p.Lon}) > radiusNM
+	})
+	return decoded, nil
+}
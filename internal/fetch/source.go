@@ -0,0 +1,28 @@
+package fetch
+
+import (
+	"net/http"
+	"time"
+)
+
+// Source points fetches at another aviationweather.gov-compatible API or
+// HTTP client. The zero Source uses aviationweather.gov and a client with a
+// 10s timeout.
+type Source struct {
+	BaseURL string // e.g. "https://aviationweather.gov/api/data"
+	Client  *http.Client
+}
+
+func (s Source) base() string {
+	if s.BaseURL != "" {
+		return s.BaseURL
+	}
+	return baseURL
+}
+
+func (s Source) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
@@ -0,0 +1,81 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/house-holder/pilot-bar/internal/geo"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// GetStations loads metadata for every station inside bbox
+func (s Source) GetStations(ctx context.Context, bbox string, maxAttempts int) ([]types.StationInfo, error) {
+	url := fmt.Sprintf("%s/stationinfo?format=json&bbox=%s", s.base(), bbox)
+
+	var decoded []types.StationInfo
+	if err := s.getJSON(ctx, url, "station info", maxAttempts, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// GetAirportInfo looks up location data for any airport, reporting or not
+func (s Source) GetAirportInfo(ctx context.Context, id string, maxAttempts int) (types.StationInfo, error) {
+	url := fmt.Sprintf("%s/airport?format=json&ids=%s", s.base(), id)
+
+	var decoded []types.StationInfo
+	if err := s.getJSON(ctx, url, "airport info", maxAttempts, &decoded); err != nil {
+		return types.StationInfo{}, err
+	}
+	if len(decoded) == 0 {
+		return types.StationInfo{}, fmt.Errorf("airport info for %s: %w", id, ErrNoData)
+	}
+	return decoded[0], nil
+}
+
+// GetNearestMETAR returns the latest METAR from the reporting station closest
+// to center, and its distance
+func (s Source) GetNearestMETAR(ctx context.Context, center geo.Point, radiusNM float64, maxAttempts int) (types.METARresponse, float64, error) {
+	url := fmt.Sprintf("%s/metar?format=json&bbox=%s", s.base(), geo.BBox(center, radiusNM))
+
+	var decoded []types.METARresponse
+	if err := s.getJSON(ctx, url, "nearby METAR", maxAttempts, &decoded); err != nil {
+		return types.METARresponse{}, 0, err
+	}
+	i, dist := nearest(center, radiusNM, len(decoded), func(i int) geo.Point {
+		return geo.Point{Lat: decoded[i].Lat, Lon: decoded[i].Long}
+	})
+	if i < 0 {
+		return types.METARresponse{}, 0, fmt.Errorf("METAR within %.0f NM: %w", radiusNM, ErrNoData)
+	}
+	return decoded[i], dist, nil
+}
+
+// GetNearestTAF returns the TAF from the station closest to center, and its
+// distance
+func (s Source) GetNearestTAF(ctx context.Context, center geo.Point, radiusNM float64, maxAttempts int) (types.TAFresponse, float64, error) {
+	url := fmt.Sprintf("%s/taf?format=json&bbox=%s", s.base(), geo.BBox(center, radiusNM))
+
+	var decoded []types.TAFresponse
+	if err := s.getJSON(ctx, url, "nearby TAF", maxAttempts, &decoded); err != nil {
+		return types.TAFresponse{}, 0, err
+	}
+	i, dist := nearest(center, radiusNM, len(decoded), func(i int) geo.Point {
+		return geo.Point{Lat: decoded[i].Lat, Lon: decoded[i].Lon}
+	})
+	if i < 0 {
+		return types.TAFresponse{}, 0, fmt.Errorf("TAF within %.0f NM: %w", radiusNM, ErrNoData)
+	}
+	return decoded[i], dist, nil
+}
+
+// nearest returns the index of the closest of n points within radiusNM, or -1
+func nearest(center geo.Point, radiusNM float64, n int, at func(int) geo.Point) (int, float64) {
+	best, bestDist := -1, radiusNM
+	for i := range n {
+		if dist := geo.DistanceNM(center, at(i)); dist <= bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best, bestDist
+}
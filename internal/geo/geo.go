@@ -0,0 +1,87 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+)
+
+const earthRadiusNM = 3440.065
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// DistanceNM returns the great-circle distance between two points in nautical miles
+func DistanceNM(a, b Point) float64 {
+	lat1, lat2 := toRad(a.Lat), toRad(b.Lat)
+	dLat := lat2 - lat1
+	dLon := toRad(b.Lon - a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusNM * math.Asin(math.Sqrt(h))
+}
+
+// BBox returns a lat0,lon0,lat1,lon1 box enclosing radiusNM around center, in
+// the form the aviationweather.gov API expects
+func BBox(center Point, radiusNM float64) string {
+	dLat := radiusNM / 60.0
+	dLon := dLat
+	if c := math.Cos(toRad(center.Lat)); c > 0.01 {
+		dLon = dLat / c
+	}
+	return fmt.Sprintf("%.3f,%.3f,%.3f,%.3f",
+		center.Lat-dLat, center.Lon-dLon, center.Lat+dLat, center.Lon+dLon)
+}
+
+func toRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// InPolygon reports whether p lies inside the closed polygon poly
+func InPolygon(p Point, poly []Point) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// NearPolygon reports whether p is inside poly or within radiusNM of its edge
+func NearPolygon(p Point, poly []Point, radiusNM float64) bool {
+	if InPolygon(p, poly) {
+		return true
+	}
+	if radiusNM <= 0 || len(poly) == 0 {
+		return false
+	}
+
+	// flat projection around p is plenty accurate at hazard-check distances
+	scale := math.Cos(toRad(p.Lat))
+	project := func(q Point) (x, y float64) {
+		return (q.Lon - p.Lon) * 60 * scale, (q.Lat - p.Lat) * 60
+	}
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		ax, ay := project(poly[j])
+		bx, by := project(poly[i])
+		if segmentDistance(ax, ay, bx, by) <= radiusNM {
+			return true
+		}
+	}
+	return false
+}
+
+// segmentDistance is the distance from the origin to segment a-b
+func segmentDistance(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
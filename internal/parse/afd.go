@@ -0,0 +1,97 @@
+package parse
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+var (
+	afdIssued  = regexp.MustCompile(`^\d{3,4} [AP]M [A-Z]{3,4} [A-Z][a-z]{2} [A-Z][a-z]{2} \d{1,2} \d{4}$`)
+	afdHeader  = regexp.MustCompile(`^\.([A-Z][^.]*?)\.\.\.(.*)$`)
+	afdCredits = regexp.MustCompile(`^[A-Z/ ]+\.\.\.(.+)$`)
+)
+
+// ParseAFD splits a discussion into its dot-headed sections, along with the
+// issuing office, issuance time and forecaster credits after the $$
+func ParseAFD(raw string) types.AFD {
+	var afd types.AFD
+	var current *types.AFDSection
+	var body []string
+	var credits []string
+	signed := false
+
+	flush := func() {
+		if current != nil {
+			current.Body = strings.TrimSpace(strings.Join(body, "\n"))
+			afd.Sections = append(afd.Sections, *current)
+		}
+		current, body = nil, nil
+	}
+
+	for line := range strings.SplitSeq(raw, "\n") {
+		line = strings.TrimRight(line, " \r")
+		switch {
+		case signed:
+			if line == "" {
+				continue
+			}
+			if m := afdCredits.FindStringSubmatch(line); m != nil {
+				credits = appendUnique(credits, strings.TrimSpace(m[1]))
+			} else {
+				credits = appendUnique(credits, strings.TrimSpace(line))
+			}
+		case line == "$$":
+			flush()
+			signed = true
+		case line == "&&":
+			flush()
+		case afd.Office == "" && strings.HasPrefix(line, "National Weather Service"):
+			afd.Office = line
+		case afd.Issued == "" && afdIssued.MatchString(line):
+			afd.Issued = line
+		default:
+			if m := afdHeader.FindStringSubmatch(line); m != nil {
+				flush()
+				current = &types.AFDSection{
+					Name:  afdSectionName(m[1]),
+					Title: "." + strings.TrimSpace(m[1]),
+				}
+				if rest := strings.TrimSpace(m[2]); rest != "" {
+					body = append(body, rest)
+				}
+				continue
+			}
+			if current != nil {
+				body = append(body, line)
+			}
+		}
+	}
+	flush()
+
+	afd.Forecaster = strings.Join(credits, ", ")
+	return afd
+}
+
+// afdSectionName drops timing qualifiers and office prefixes, so
+// "NEAR TERM /THROUGH TONIGHT/" becomes "NEAR TERM" and
+// "PAH WATCHES/WARNINGS/ADVISORIES" becomes "WATCHES/WARNINGS"
+func afdSectionName(title string) string {
+	title = strings.TrimSpace(title)
+	if strings.Contains(title, "WATCHES") {
+		return "WATCHES/WARNINGS"
+	}
+	if i := strings.Index(title, " /"); i > 0 {
+		title = title[:i]
+	}
+	return strings.TrimSpace(title)
+}
+
+func appendUnique(list []string, s string) []string {
+	if slices.Contains(list, s) {
+		return list
+	}
+	return append(list, s)
+}
@@ -0,0 +1,82 @@
+package parse
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseAFD(t *testing.T) {
+	raw, err := os.ReadFile("../../testdata/afd.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	afd := ParseAFD(string(raw))
+
+	if afd.Office != "National Weather Service Paducah KY" {
+		t.Errorf("office = %q", afd.Office)
+	}
+	if afd.Issued != "621 AM CDT Sun Oct 19 2026" {
+		t.Errorf("issued = %q", afd.Issued)
+	}
+	if afd.Forecaster != "DRS, JGG" {
+		t.Errorf("forecaster = %q, want credits from after $$", afd.Forecaster)
+	}
+
+	var names []string
+	for _, s := range afd.Sections {
+		names = append(names, s.Name)
+	}
+	want := "KEY MESSAGES,DISCUSSION,AVIATION,WATCHES/WARNINGS"
+	if got := strings.Join(names, ","); got != want {
+		t.Fatalf("sections = %s, want %s", got, want)
+	}
+
+	aviation, ok := afd.Section("AVIATION")
+	if !ok {
+		t.Fatal("no AVIATION section")
+	}
+	if aviation.Title != ".AVIATION" {
+		t.Errorf("title = %q", aviation.Title)
+	}
+	if !strings.HasPrefix(aviation.Body, "Issued at 620 AM") || !strings.HasSuffix(aviation.Body, "after 15Z.") {
+		t.Errorf("AVIATION body = %q", aviation.Body)
+	}
+	// && ends a section; nothing after it leaks in
+	for _, s := range afd.Sections {
+		if strings.Contains(s.Body, "&&") || strings.Contains(s.Body, "$$") {
+			t.Errorf("%s body runs past its terminator: %q", s.Name, s.Body)
+		}
+	}
+	watches, _ := afd.Section("WATCHES/WARNINGS")
+	if watches.Body != "IL...None.\nMO...None.\nIN...None.\nKY...None." {
+		t.Errorf("WATCHES/WARNINGS body = %q", watches.Body)
+	}
+}
+
+func TestParseAFDUnterminated(t *testing.T) {
+	afd := ParseAFD(".SYNOPSIS...Cold front Tuesday.\nRain behind it.\n\n.AVIATION /12Z TAFS/...\nVFR.")
+	if len(afd.Sections) != 2 {
+		t.Fatalf("got %d sections, want 2: %+v", len(afd.Sections), afd.Sections)
+	}
+	if s := afd.Sections[0]; s.Name != "SYNOPSIS" || s.Body != "Cold front Tuesday.\nRain behind it." {
+		t.Errorf("first section = %+v", s)
+	}
+	if s := afd.Sections[1]; s.Name != "AVIATION" || s.Title != ".AVIATION /12Z TAFS/" || s.Body != "VFR." {
+		t.Errorf("second section = %+v", s)
+	}
+}
+
+func TestAFDSectionName(t *testing.T) {
+	tests := map[string]string{
+		"NEAR TERM /THROUGH TONIGHT/":     "NEAR TERM",
+		"PAH WATCHES/WARNINGS/ADVISORIES": "WATCHES/WARNINGS",
+		"AVIATION /06Z TAFS/":             "AVIATION",
+		" SYNOPSIS ":                      "SYNOPSIS",
+	}
+	for in, want := range tests {
+		if got := afdSectionName(in); got != want {
+			t.Errorf("afdSectionName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
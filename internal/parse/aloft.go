@@ -0,0 +1,226 @@
+package parse
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/house-holder/pilot-bar/internal/geo"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+var (
+	fbValid  = regexp.MustCompile(`VALID (\d{6}Z)\s+FOR USE (\d{4}-\d{4}Z)`)
+	fbColumn = regexp.MustCompile(`\S+`)
+)
+
+// ParseWindsAloft splits an FB product into one entry per forecast site, keyed
+// by the 3-letter site ID. A malformed group is logged and left out rather
+// than costing the rest of the product.
+func ParseWindsAloft(text string) (map[string]types.WindsAloft, error) {
+	var valid, forUse string
+	if m := fbValid.FindStringSubmatch(text); m != nil {
+		valid, forUse = m[1], m[2]
+	}
+
+	var header []fbHeader
+	sites := make(map[string]types.WindsAloft)
+	for line := range strings.SplitSeq(text, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if strings.HasPrefix(line, "FT ") {
+			header = parseFBHeader(line)
+			continue
+		}
+		if header == nil || len(line) < 4 {
+			continue
+		}
+
+		fields := fbColumn.FindAllStringIndex(line, -1)
+		if len(fields) < 2 || fields[0][1] != 3 {
+			continue
+		}
+		site := types.WindsAloft{
+			Station: line[:3],
+			Valid:   valid,
+			ForUse:  forUse,
+			Levels:  make([]types.AloftLevel, 0, len(fields)-1),
+		}
+		for _, f := range fields[1:] {
+			alt := header[nearestColumn(header, f[1])].altitude
+			level, err := decodeFBGroup(line[f[0]:f[1]], alt)
+			if err != nil {
+				slog.Warn("Skipping FB group", "site", site.Station, "altitude", alt, "error", err)
+				continue
+			}
+			site.Levels = append(site.Levels, level)
+		}
+		sites[site.Station] = site
+	}
+
+	if header == nil {
+		return nil, fmt.Errorf("FB product has no FT header")
+	}
+	return sites, nil
+}
+
+// fbHeader is one altitude column; groups in data rows end where it ends
+type fbHeader struct {
+	altitude types.Feet
+	end      int
+}
+
+func parseFBHeader(line string) []fbHeader {
+	var cols []fbHeader
+	for _, f := range fbColumn.FindAllStringIndex(line, -1)[1:] {
+		alt, err := strconv.Atoi(line[f[0]:f[1]])
+		if err != nil {
+			continue
+		}
+		cols = append(cols, fbHeader{altitude: types.Feet(alt), end: f[1]})
+	}
+	return cols
+}
+
+func nearestColumn(header []fbHeader, end int) int {
+	best := 0
+	for i, col := range header {
+		if abs(col.end-end) < abs(header[best].end-end) {
+			best = i
+		}
+	}
+	return best
+}
+
+// decodeFBGroup reads DDSS, DDSS±TT or DDSSTT. 9900 is light and variable,
+// directions of 51-86 encode 100-199 knots, and temperatures above 24000
+// carry no sign because they are always negative.
+func decodeFBGroup(group string, alt types.Feet) (types.AloftLevel, error) {
+	level := types.AloftLevel{Altitude: alt}
+	if len(group) < 4 {
+		return level, fmt.Errorf("short group %q", group)
+	}
+
+	dir, err := strconv.Atoi(group[:2])
+	if err != nil {
+		return level, err
+	}
+	speed, err := strconv.Atoi(group[2:4])
+	if err != nil {
+		return level, err
+	}
+
+	switch {
+	case (dir > 36 && dir <= 50) || (dir > 86 && dir != 99):
+		return level, fmt.Errorf("bad direction in %q", group)
+	case dir == 99:
+		level.Wind.Variable = true
+	case dir > 50:
+		level.Wind.Direction = types.DegMag((dir - 50) * 10)
+		level.Wind.Speed = types.Knots(speed + 100)
+	default:
+		level.Wind.Direction = types.DegMag(dir * 10)
+		level.Wind.Speed = types.Knots(speed)
+	}
+	level.Wind.Calm = level.Wind.Speed == 0
+
+	if tempStr := group[4:]; tempStr != "" {
+		temp, err := strconv.Atoi(tempStr)
+		if err != nil {
+			return level, err
+		}
+		if tempStr[0] != '+' && tempStr[0] != '-' {
+			temp = -temp
+		}
+		level.Temp = &temp
+	}
+	return level, nil
+}
+
+// InterpolateAloft estimates wind and temperature at alt from the bracketing
+// forecast levels. Winds are blended as vectors so 350 and 010 average to 360.
+func InterpolateAloft(w types.WindsAloft, alt types.Feet) (types.AloftLevel, bool) {
+	for i, lo := range w.Levels {
+		if lo.Altitude == alt {
+			return lo, true
+		}
+		if i+1 >= len(w.Levels) {
+			break
+		}
+		hi := w.Levels[i+1]
+		if alt < lo.Altitude || alt > hi.Altitude {
+			continue
+		}
+		if alt == hi.Altitude {
+			return hi, true
+		}
+
+		f := float64(alt-lo.Altitude) / float64(hi.Altitude-lo.Altitude)
+		level := types.AloftLevel{Altitude: alt}
+
+		u1, v1 := windVector(lo.Wind)
+		u2, v2 := windVector(hi.Wind)
+		u, v := u1+(u2-u1)*f, v1+(v2-v1)*f
+		speed := math.Round(math.Hypot(u, v))
+		if lo.Wind.Variable && hi.Wind.Variable {
+			level.Wind.Variable = true
+		} else if speed > 0 {
+			dir := math.Mod(math.Atan2(u, v)*180/math.Pi+360, 360)
+			dir = math.Round(dir/10) * 10
+			if dir == 0 {
+				dir = 360
+			}
+			level.Wind.Direction = types.DegMag(dir)
+			level.Wind.Speed = types.Knots(speed)
+		}
+		level.Wind.Calm = level.Wind.Speed == 0
+
+		if lo.Temp != nil && hi.Temp != nil {
+			temp := int(math.Round(float64(*lo.Temp) + float64(*hi.Temp-*lo.Temp)*f))
+			level.Temp = &temp
+		}
+		return level, true
+	}
+	return types.AloftLevel{}, false
+}
+
+// windVector returns the east/north components the wind blows from
+func windVector(w types.WindData) (u, v float64) {
+	if w.Variable {
+		return 0, 0
+	}
+	rad := float64(w.Direction) * math.Pi / 180
+	return float64(w.Speed) * math.Sin(rad), float64(w.Speed) * math.Cos(rad)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// NearestFBSite matches stations against the FB site IDs and returns the
+// closest site's forecast
+func NearestFBSite(sites map[string]types.WindsAloft, stations []types.StationInfo, from geo.Point) (types.WindsAloft, bool) {
+	var best types.WindsAloft
+	found := false
+	for _, st := range stations {
+		if len(st.IcaoID) != 4 {
+			continue
+		}
+		site, ok := sites[st.IcaoID[1:]]
+		if !ok {
+			continue
+		}
+		dist := geo.DistanceNM(from, geo.Point{Lat: st.Lat, Lon: st.Lon})
+		if !found || dist < best.DistanceNM {
+			best = site
+			best.Lat, best.Lon, best.DistanceNM = st.Lat, st.Lon, dist
+			found = true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,126 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// trimmed from a real FBUS31 KWNO product; XYZ is made up and has a garbled
+// 6000 group and an impossible direction at 9000
+const fbProduct = `000
+FBUS31 KWNO 191359
+FD1US1
+DATA BASED ON 191200Z
+VALID 191800Z   FOR USE 1400-2100Z. TEMPS NEG ABV 24000
+
+FT  3000    6000    9000   12000   18000   24000  30000  34000  39000
+BFF      2815+03 2925-02 2938-07 2962-20 2980-32 780446 780856 780858
+BRO 1315 1717+18 2010+12 2415+07 2626-07 2740-18 275732 276541 276552
+STL 9900 2608+09 2712+04 2826-02 2845-15 2862-27 781042 781650 289958
+XYZ 1315 17ZZ+18 4520+12 2415+07
+`
+
+func TestParseWindsAloft(t *testing.T) {
+	sites, err := ParseWindsAloft(fbProduct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 4 {
+		t.Fatalf("got %d sites, want 4", len(sites))
+	}
+
+	bro := sites["BRO"]
+	if bro.Valid != "191800Z" || bro.ForUse != "1400-2100Z" {
+		t.Errorf("BRO valid %q for use %q", bro.Valid, bro.ForUse)
+	}
+	if len(bro.Levels) != 9 {
+		t.Errorf("BRO has %d levels, want 9", len(bro.Levels))
+	}
+
+	tests := []struct {
+		site     string
+		alt      types.Feet
+		dir      types.DegMag
+		speed    types.Knots
+		variable bool
+		temp     *int
+	}{
+		{"BRO", 3000, 130, 15, false, nil},        // no temperature at 3000
+		{"BRO", 6000, 170, 17, false, ptr(18)},    // signed
+		{"BRO", 24000, 270, 40, false, ptr(-18)},  // still signed at 24000
+		{"BRO", 30000, 270, 57, false, ptr(-32)},  // implied negative above
+		{"BFF", 30000, 280, 104, false, ptr(-46)}, // 78 is 280 at 100+
+		{"STL", 3000, 0, 0, true, nil},            // 9900 light and variable
+		{"STL", 34000, 280, 116, false, ptr(-50)},
+		{"STL", 39000, 280, 99, false, ptr(-58)},
+	}
+	for _, tt := range tests {
+		level, ok := levelAt(sites[tt.site], tt.alt)
+		if !ok {
+			t.Errorf("%s has no %d level", tt.site, tt.alt)
+			continue
+		}
+		w := level.Wind
+		if w.Variable != tt.variable || (!tt.variable && (w.Direction != tt.dir || w.Speed != tt.speed)) {
+			t.Errorf("%s %d wind = %+v, want %03d/%d variable %v", tt.site, tt.alt, w, tt.dir, tt.speed, tt.variable)
+		}
+		if !equalPtr(level.Temp, tt.temp) {
+			t.Errorf("%s %d temp = %v, want %v", tt.site, tt.alt, deref(level.Temp), deref(tt.temp))
+		}
+	}
+
+	// BFF's 3000 column is blank: the station is above it
+	if _, ok := levelAt(sites["BFF"], 3000); ok {
+		t.Error("BFF has a 3000 level")
+	}
+	if first := sites["BFF"].Levels[0].Altitude; first != 6000 {
+		t.Errorf("BFF starts at %d, want 6000", first)
+	}
+
+	// the bad groups go, the rest of the row stays
+	xyz := sites["XYZ"]
+	if len(xyz.Levels) != 2 {
+		t.Errorf("XYZ kept %d levels, want 2: %+v", len(xyz.Levels), xyz.Levels)
+	}
+	if _, ok := levelAt(xyz, 12000); !ok {
+		t.Error("XYZ lost its 12000 level")
+	}
+}
+
+func TestParseWindsAloftNoHeader(t *testing.T) {
+	if _, err := ParseWindsAloft("BRO 1315 1717+18"); err == nil {
+		t.Error("want an error for a product with no FT line")
+	}
+}
+
+func TestInterpolateAloft(t *testing.T) {
+	w := types.WindsAloft{Levels: []types.AloftLevel{
+		{Altitude: 6000, Wind: types.WindData{Direction: 350, Speed: 20}, Temp: ptr(10)},
+		{Altitude: 9000, Wind: types.WindData{Direction: 10, Speed: 20}, Temp: ptr(4)},
+	}}
+	level, ok := InterpolateAloft(w, 7500)
+	if !ok {
+		t.Fatal("no level between 6000 and 9000")
+	}
+	if level.Wind.Direction != 360 || level.Wind.Speed != 20 {
+		t.Errorf("wind = %+v, want 360/20", level.Wind)
+	}
+	if deref(level.Temp) != 7 {
+		t.Errorf("temp = %d, want 7", deref(level.Temp))
+	}
+	if _, ok := InterpolateAloft(w, 12000); ok {
+		t.Error("extrapolated above the top level")
+	}
+}
+
+func levelAt(w types.WindsAloft, alt types.Feet) (types.AloftLevel, bool) {
+	for _, l := range w.Levels {
+		if l.Altitude == alt {
+			return l, true
+		}
+	}
+	return types.AloftLevel{}, false
+}
+
+func ptr(n int) *int { return &n }
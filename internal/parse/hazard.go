@@ -0,0 +1,147 @@
+package parse
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/geo"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// G-AIRMET snapshots are issued every 3 hours
+const gairmetSpan = 3 * time.Hour
+
+// hazardKinds maps API hazard codes onto the kinds we report
+var hazardKinds = map[string]string{
+	"IFR":        "IFR",
+	"MTN OBSCN":  "MTN OBSC",
+	"MTN OBSC":   "MTN OBSC",
+	"MT_OBSC":    "MTN OBSC",
+	"TURB":       "TURB",
+	"TURB-HI":    "TURB",
+	"TURB-LO":    "TURB",
+	"ICE":        "ICE",
+	"LLWS":       "LLWS",
+	"CONVECTIVE": "CONVECTIVE",
+	"CONV":       "CONVECTIVE",
+}
+
+// BuildHazards keeps the active hazards whose area contains station, or comes
+// within radiusNM of it
+func BuildHazards(
+	sigmets, airmets []types.AirSigmetResponse,
+	gairmets []types.GAIRMETresponse,
+	station geo.Point, radiusNM float64, now time.Time,
+) []types.Hazard {
+	hazards := make([]types.Hazard, 0)
+	add := func(h types.Hazard, coords []types.Coord) {
+		if now.Unix() < h.ValidFrom || now.Unix() >= h.ValidTo {
+			return
+		}
+		poly, ok := polygon(coords)
+		if !ok || !geo.NearPolygon(station, poly, radiusNM) {
+			return
+		}
+		if slices.ContainsFunc(hazards, func(o types.Hazard) bool {
+			return o.Product == h.Product && o.Kind == h.Kind && o.Detail == h.Detail
+		}) {
+			return
+		}
+		hazards = append(hazards, h)
+	}
+
+	for _, list := range [][]types.AirSigmetResponse{sigmets, airmets} {
+		for _, a := range list {
+			kind, ok := hazardKinds[strings.ToUpper(a.Hazard)]
+			if !ok {
+				continue
+			}
+			add(types.Hazard{
+				Product:   strings.ToUpper(a.AirSigmetType),
+				Kind:      kind,
+				Detail:    a.Hazard,
+				Severity:  fmt.Sprint(a.Severity),
+				ValidFrom: a.ValidTimeFrom,
+				ValidTo:   a.ValidTimeTo,
+				Base:      feetPtr(a.AltitudeLow1),
+				Top:       feetPtr(a.AltitudeHi1),
+				Raw:       a.RawAirSigmet,
+			}, a.Coords)
+		}
+	}
+
+	for _, g := range gairmets {
+		kind, ok := hazardKinds[strings.ToUpper(g.Hazard)]
+		if !ok {
+			continue
+		}
+		valid, err := time.Parse(time.RFC3339, g.ValidTime)
+		if err != nil {
+			continue
+		}
+		detail := g.Hazard
+		if g.DueTo != "" {
+			detail = fmt.Sprintf("%s (%s)", g.Hazard, g.DueTo)
+		}
+		add(types.Hazard{
+			Product:   "G-AIRMET",
+			Kind:      kind,
+			Detail:    detail,
+			ValidFrom: valid.Unix(),
+			ValidTo:   valid.Add(gairmetSpan).Unix(),
+			Base:      gairmetAltitude(g.Base),
+			Top:       gairmetAltitude(g.Top),
+		}, g.Coords)
+	}
+	return hazards
+}
+
+// polygon converts an area's vertices, or reports false when one of them
+// came without a usable lat or lon
+func polygon(coords []types.Coord) ([]geo.Point, bool) {
+	poly := make([]geo.Point, len(coords))
+	for i, c := range coords {
+		if math.IsNaN(c.Lat) || math.IsNaN(c.Lon) {
+			return nil, false
+		}
+		poly[i] = geo.Point{Lat: c.Lat, Lon: c.Lon}
+	}
+	return poly, true
+}
+
+// feetPtr converts the AIRMET/SIGMET altitudes, which are in feet
+func feetPtr(v *int) *types.Feet {
+	if v == nil {
+		return nil
+	}
+	alt := types.Feet(*v)
+	return &alt
+}
+
+// gairmetAltitude reads a G-AIRMET base or top: hundreds of feet as a
+// string or number, or "SFC". "FZL" and anything else unreadable gives nil.
+func gairmetAltitude(v any) *types.Feet {
+	var n float64
+	switch val := v.(type) {
+	case float64:
+		n = val
+	case string:
+		if val == "SFC" {
+			zero := types.Feet(0)
+			return &zero
+		}
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil
+		}
+		n = f
+	default:
+		return nil
+	}
+	alt := types.Feet(n * 100)
+	return &alt
+}
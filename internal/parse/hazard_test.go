@@ -0,0 +1,79 @@
+package parse
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/geo"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+func TestBuildHazards(t *testing.T) {
+	now := time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC)
+	from, to := now.Add(-time.Hour).Unix(), now.Add(time.Hour).Unix()
+
+	// one AIRMET over the station, one 60 NM east of it, and one whose
+	// second vertex has no lon
+	var airmets []types.AirSigmetResponse
+	err := json.Unmarshal([]byte(`[
+		{"airSigmetType":"AIRMET","hazard":"IFR","validTimeFrom":`+itoa(from)+`,"validTimeTo":`+itoa(to)+`,
+		 "altitudeLow1":0,"altitudeHi1":12000,
+		 "coords":[{"lat":36,"lon":-91},{"lat":38,"lon":-91},{"lat":38,"lon":-89},{"lat":36,"lon":-89}]},
+		{"airSigmetType":"AIRMET","hazard":"TURB","validTimeFrom":`+itoa(from)+`,"validTimeTo":`+itoa(to)+`,
+		 "altitudeLow1":18000,"altitudeHi1":39000,
+		 "coords":[{"lat":36,"lon":-88.5},{"lat":38,"lon":-88.5},{"lat":38,"lon":-87},{"lat":36,"lon":-87}]},
+		{"airSigmetType":"AIRMET","hazard":"ICE","validTimeFrom":`+itoa(from)+`,"validTimeTo":`+itoa(to)+`,
+		 "coords":[{"lat":36,"lon":-91},{"lat":"38"},{"lat":38,"lon":-89},{"lat":36,"lon":-89}]}
+	]`), &airmets)
+	if err != nil {
+		t.Fatalf("one bad vertex failed the whole decode: %v", err)
+	}
+
+	var gairmets []types.GAIRMETresponse
+	err = json.Unmarshal([]byte(`[
+		{"validTime":"`+now.Add(-time.Hour).Format(time.RFC3339)+`","hazard":"ICE","base":"FZL","top":"180",
+		 "coords":[{"lat":"36","lon":"-91"},{"lat":"38","lon":"-91"},{"lat":"38","lon":"-89"},{"lat":"36","lon":"-89"}]},
+		{"validTime":"`+now.Add(-time.Hour).Format(time.RFC3339)+`","hazard":"MT_OBSC","base":"SFC","top":8,
+		 "coords":[{"lat":"36","lon":"-91"},{"lat":"38","lon":"-91"},{"lat":"38","lon":"-89"},{"lat":"36","lon":"-89"}]}
+	]`), &gairmets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	station := geo.Point{Lat: 37.2, Lon: -89.6}
+
+	point := BuildHazards(nil, airmets, gairmets, station, 0, now)
+	if len(point) != 3 {
+		t.Fatalf("radius 0: got %d hazards, want 3: %+v", len(point), point)
+	}
+	want := []struct {
+		kind      string
+		base, top *types.Feet
+	}{
+		{"IFR", feet(0), feet(12000)},
+		{"ICE", nil, feet(18000)},
+		{"MTN OBSC", feet(0), feet(800)},
+	}
+	for i, w := range want {
+		h := point[i]
+		if h.Kind != w.kind || !equalPtr(h.Base, w.base) || !equalPtr(h.Top, w.top) {
+			t.Errorf("hazard %d = %s %v-%v, want %s %v-%v", i,
+				h.Kind, deref(h.Base), deref(h.Top), w.kind, deref(w.base), deref(w.top))
+		}
+	}
+
+	if wide := BuildHazards(nil, airmets, gairmets, station, 100, now); len(wide) != 4 {
+		t.Errorf("radius 100: got %d hazards, want 4", len(wide))
+	}
+	if later := BuildHazards(nil, airmets, gairmets, station, 100, now.Add(5*time.Hour)); len(later) != 0 {
+		t.Errorf("after expiry: got %d hazards, want 0", len(later))
+	}
+}
+
+func feet(f types.Feet) *types.Feet { return &f }
+
+func itoa(n int64) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
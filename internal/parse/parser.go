@@ -3,6 +3,7 @@ package parse
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"slices"
 	"strconv"
 	"strings"
@@ -19,8 +20,14 @@ type ParseContext struct {
 
 type parseFunc func(c *ParseContext) error
 
+// Elevation converts the API's station elevation, in metres, to feet
+func Elevation(meters int) types.Feet {
+	return types.Feet(math.Round(float64(meters) * 3.28084))
+}
+
 func BuildInternalMETAR(data *types.METARresponse, output *types.METAR) error {
 	output.RawOb = data.RawOb
+	output.Type = data.MetarType
 	output.FltCat = data.FltCat
 	output.WxString = data.WxString
 
@@ -0,0 +1,265 @@
+package parse
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/house-holder/pilot-bar/internal/geo"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// pirepField matches a field code followed by a space, a digit (as in
+// /FL080) or the end, so /OVC070 inside a sky group isn't an /OV field
+var pirepField = regexp.MustCompile(`/(OV|TM|FL|TP|SK|WX|TA|WV|TB|IC|RM)(?:\s|\d|$)`)
+
+var (
+	pirepIntensities = []string{"NEG", "TRACE", "TRC", "SMTH", "LGT", "MOD", "SEV", "EXTRM", "EXTM"}
+	pirepFrequencies = []string{"OCNL", "INTMT", "CONS"}
+	pirepTypes       = []string{"RIME", "CLR", "MX", "MXD", "CHOP", "CAT", "LLWS"}
+)
+
+// BuildInternalPIREP decodes a report, preferring the API's decoded fields and
+// falling back to the raw text for anything the JSON leaves empty
+func BuildInternalPIREP(data *types.PIREPresponse, station geo.Point) types.PIREP {
+	p := DecodePIREP(data.RawOb)
+	p.Epoch = data.ObsTime
+	p.Lat = data.Lat
+	p.Lon = data.Lon
+	p.DistanceNM = geo.DistanceNM(station, geo.Point{Lat: data.Lat, Lon: data.Lon})
+	if data.PirepType == "Urgent PIREP" || data.PirepType == "UUA" {
+		p.Urgent = true
+	}
+
+	if data.FltLvl != nil {
+		alt := types.Feet(*data.FltLvl * 100)
+		p.Altitude = &alt
+	}
+	if data.AcType != "" {
+		p.AircraftType = data.AcType
+	}
+	if data.WxString != "" {
+		p.Weather = data.WxString
+	}
+	if data.Temp != nil {
+		temp := int(*data.Temp)
+		p.Temp = &temp
+	}
+	if data.Wdir != nil && data.Wspd != nil {
+		p.Wind = &types.WindData{
+			Direction: types.DegMag(*data.Wdir),
+			Speed:     types.Knots(*data.Wspd),
+		}
+	}
+
+	icing := jsonConditions(
+		[2]string{data.IcgInt1, data.IcgInt2},
+		[2]string{data.IcgType1, data.IcgType2},
+		[2]string{},
+		[2]*int{data.IcgBas1, data.IcgBas2},
+		[2]*int{data.IcgTop1, data.IcgTop2},
+	)
+	if len(icing) > 0 {
+		p.Icing = icing
+	}
+	turbulence := jsonConditions(
+		[2]string{data.TbInt1, data.TbInt2},
+		[2]string{data.TbType1, data.TbType2},
+		[2]string{data.TbFreq1, data.TbFreq2},
+		[2]*int{data.TbBas1, data.TbBas2},
+		[2]*int{data.TbTop1, data.TbTop2},
+	)
+	if len(turbulence) > 0 {
+		p.Turbulence = turbulence
+	}
+	return p
+}
+
+func jsonConditions(intensity, kind, freq [2]string, base, top [2]*int) []types.PIREPCondition {
+	var out []types.PIREPCondition
+	for i := range 2 {
+		if intensity[i] == "" {
+			continue
+		}
+		c := types.PIREPCondition{
+			Intensity: intensity[i],
+			Type:      kind[i],
+			Frequency: freq[i],
+		}
+		if base[i] != nil {
+			b := types.Feet(*base[i] * 100)
+			c.Base = &b
+		}
+		if top[i] != nil {
+			t := types.Feet(*top[i] * 100)
+			c.Top = &t
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// DecodePIREP decodes the slash-delimited fields of a raw UA/UUA report
+func DecodePIREP(raw string) types.PIREP {
+	p := types.PIREP{Raw: raw}
+	p.Urgent = strings.Contains(raw, " UUA ") || strings.HasPrefix(raw, "UUA ")
+
+	locs := pirepField.FindAllStringSubmatchIndex(raw, -1)
+	for i, loc := range locs {
+		end := len(raw)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		code := raw[loc[2]:loc[3]]
+		if code == "RM" {
+			// remarks are free text and run to the end, slashes and all
+			p.Remarks = strings.TrimSpace(raw[loc[3]:])
+			break
+		}
+		value := strings.TrimSpace(raw[loc[3]:end])
+
+		switch code {
+		case "OV":
+			p.Location = value
+		case "TM":
+			p.Time = value
+		case "FL":
+			p.Altitude = pirepAltitude(value)
+		case "TP":
+			p.AircraftType = value
+		case "SK":
+			p.Sky = value
+		case "WX":
+			p.Weather = value
+		case "TA":
+			p.Temp = pirepTemp(value)
+		case "WV":
+			p.Wind = pirepWind(value)
+		case "TB":
+			p.Turbulence = pirepConditions(value)
+		case "IC":
+			p.Icing = pirepConditions(value)
+		}
+	}
+	return p
+}
+
+// pirepAltitude reads a 3-digit hundreds-of-feet value, e.g. "010" or "FL045"
+func pirepAltitude(s string) *types.Feet {
+	s = strings.TrimPrefix(s, "FL")
+	if len(s) < 3 {
+		return nil
+	}
+	hundreds, err := strconv.Atoi(s[:3])
+	if err != nil {
+		return nil
+	}
+	alt := types.Feet(hundreds * 100)
+	return &alt
+}
+
+func pirepTemp(s string) *int {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil
+	}
+	s = fields[0]
+	neg := strings.HasPrefix(s, "M") || strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "M-+")
+	temp, err := strconv.Atoi(s)
+	if err != nil {
+		return nil
+	}
+	if neg {
+		temp = -temp
+	}
+	return &temp
+}
+
+// pirepWind reads dddss or dddsssKT
+func pirepWind(s string) *types.WindData {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil
+	}
+	s = strings.TrimSuffix(fields[0], "KT")
+	if len(s) < 5 {
+		return nil
+	}
+	direction, err := strconv.Atoi(s[:3])
+	if err != nil {
+		return nil
+	}
+	speed, err := strconv.Atoi(s[3:])
+	if err != nil {
+		return nil
+	}
+	return &types.WindData{
+		Direction: types.DegMag(direction),
+		Speed:     types.Knots(speed),
+	}
+}
+
+// pirepConditions decodes /TB and /IC groups such as "MOD CHOP 080-100" or
+// "LGT-MOD RIME BLO 050". Multiple layers are separated by ";".
+func pirepConditions(s string) []types.PIREPCondition {
+	var out []types.PIREPCondition
+	for _, group := range strings.Split(s, ";") {
+		var c types.PIREPCondition
+		var below, above bool
+		for _, tok := range strings.Fields(group) {
+			switch {
+			case isIntensity(tok):
+				c.Intensity = tok
+			case slices.Contains(pirepFrequencies, tok):
+				c.Frequency = tok
+			case slices.Contains(pirepTypes, tok):
+				c.Type = tok
+			case tok == "BLO" || tok == "BLW":
+				below = true
+			case tok == "ABV":
+				above = true
+			default:
+				base, top := pirepRange(tok)
+				switch {
+				case below && base != nil:
+					c.Top = base
+				case above && base != nil:
+					c.Base = base
+				case base != nil:
+					c.Base, c.Top = base, top
+				}
+			}
+		}
+		if c.Intensity != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// isIntensity accepts single intensities and ranges such as "LGT-MOD"
+func isIntensity(tok string) bool {
+	for part := range strings.SplitSeq(tok, "-") {
+		if !slices.Contains(pirepIntensities, part) {
+			return false
+		}
+	}
+	return true
+}
+
+// pirepRange reads "080" or "080-100" in hundreds of feet
+func pirepRange(tok string) (base, top *types.Feet) {
+	lo, hi, found := strings.Cut(tok, "-")
+	base = pirepAltitude(lo)
+	if base == nil {
+		return nil, nil
+	}
+	if found {
+		top = pirepAltitude(hi)
+	} else {
+		top = base
+	}
+	return base, top
+}
@@ -0,0 +1,158 @@
+package parse
+
+import (
+	"testing"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+func TestDecodePIREP(t *testing.T) {
+	feet := func(f types.Feet) *types.Feet { return &f }
+	num := func(n int) *int { return &n }
+
+	tests := []struct {
+		name     string
+		raw      string
+		location string
+		alt      *types.Feet
+		temp     *int
+		wind     *types.WindData
+		sky      string
+		turb     int
+		remarks  string
+		urgent   bool
+	}{
+		{
+			name:     "full report",
+			raw:      "DHT UA /OV DHT360015/TM 1547/FL080/TP C172/TA M05/WV 27035KT/TB MOD CHOP 070-090/RM SMOOTH ABV",
+			location: "DHT360015",
+			alt:      feet(8000),
+			temp:     num(-5),
+			wind:     &types.WindData{Direction: 270, Speed: 35},
+			turb:     1,
+			remarks:  "SMOOTH ABV",
+		},
+		{
+			name:   "urgent",
+			raw:    "UUA /OV OKC/TM 2010/FL100/TP B737/TB SEV",
+			alt:    feet(10000),
+			turb:   1,
+			urgent: true,
+		},
+		{
+			name:    "empty temp and wind",
+			raw:     "UA /OV ABC/TA /WV /RM X",
+			remarks: "X",
+		},
+		{
+			name: "ends in empty temp",
+			raw:  "UA /OV ABC/TM 1200/TA",
+		},
+		{
+			name: "ends in empty wind",
+			raw:  "UA /OV ABC/WV ",
+		},
+		{
+			name: "truncated altitude and wind",
+			raw:  "UA /OV ABC/FL08/WV 270",
+		},
+		{
+			name:    "field codes inside remarks",
+			raw:     "UA /OV ABC/FL050/RM DURC /TA M10 /WV 18020 /TB SEV",
+			alt:     feet(5000),
+			remarks: "DURC /TA M10 /WV 18020 /TB SEV",
+		},
+		{
+			name:     "sky group with OVC",
+			raw:      "UA /OV ABC/FL060/SK BKN040/OVC070/TA M02",
+			location: "ABC",
+			alt:      feet(6000),
+			temp:     num(-2),
+			sky:      "BKN040/OVC070",
+		},
+		{
+			name: "no fields",
+			raw:  "UA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DecodePIREP(tt.raw)
+			if tt.location != "" && p.Location != tt.location {
+				t.Errorf("location = %q, want %q", p.Location, tt.location)
+			}
+			if !equalPtr(p.Altitude, tt.alt) {
+				t.Errorf("altitude = %v, want %v", deref(p.Altitude), deref(tt.alt))
+			}
+			if !equalPtr(p.Temp, tt.temp) {
+				t.Errorf("temp = %v, want %v", deref(p.Temp), deref(tt.temp))
+			}
+			switch {
+			case (p.Wind == nil) != (tt.wind == nil):
+				t.Errorf("wind = %+v, want %+v", p.Wind, tt.wind)
+			case p.Wind != nil && *p.Wind != *tt.wind:
+				t.Errorf("wind = %+v, want %+v", *p.Wind, *tt.wind)
+			}
+			if tt.sky != "" && p.Sky != tt.sky {
+				t.Errorf("sky = %q, want %q", p.Sky, tt.sky)
+			}
+			if len(p.Turbulence) != tt.turb {
+				t.Errorf("turbulence = %+v, want %d layers", p.Turbulence, tt.turb)
+			}
+			if p.Remarks != tt.remarks {
+				t.Errorf("remarks = %q, want %q", p.Remarks, tt.remarks)
+			}
+			if p.Urgent != tt.urgent {
+				t.Errorf("urgent = %v, want %v", p.Urgent, tt.urgent)
+			}
+		})
+	}
+}
+
+func TestPIREPConditions(t *testing.T) {
+	tests := []struct {
+		in        string
+		want      int
+		base, top types.Feet
+	}{
+		{"MOD CHOP 080-100", 1, 8000, 10000},
+		{"LGT-MOD RIME BLO 050", 1, 0, 5000},
+		{"OCNL LGT CHOP ABV 120", 1, 12000, 0},
+		{"LGT RIME 030-050; MOD MXD 060-080", 2, 3000, 5000},
+		{"NEG", 1, 0, 0},
+		{"", 0, 0, 0},
+		{"SMOOTH", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		got := pirepConditions(tt.in)
+		if len(got) != tt.want {
+			t.Errorf("pirepConditions(%q) = %+v, want %d layers", tt.in, got, tt.want)
+			continue
+		}
+		if len(got) == 0 {
+			continue
+		}
+		if b := deref(got[0].Base); b != tt.base {
+			t.Errorf("pirepConditions(%q) base = %d, want %d", tt.in, b, tt.base)
+		}
+		if top := deref(got[0].Top); top != tt.top {
+			t.Errorf("pirepConditions(%q) top = %d, want %d", tt.in, top, tt.top)
+		}
+	}
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,440 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// decoders for raw report text, for reports that didn't come with the API's
+// decoded fields
+
+var (
+	rawStation  = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}$`)
+	rawDayTime  = regexp.MustCompile(`^(\d{2})(\d{2})(\d{2})Z$`)
+	rawWind     = regexp.MustCompile(`^(\d{3}|VRB)(\d{2,3})(?:G(\d{2,3}))?(KT|MPS)$`)
+	rawWindVar  = regexp.MustCompile(`^\d{3}V\d{3}$`)
+	rawVisSM    = regexp.MustCompile(`^([MP])?(\d+)?(?:/(\d+))?SM$`)
+	rawVisWhole = regexp.MustCompile(`^\d$`)
+	rawVisFrac  = regexp.MustCompile(`^\d/\d{1,2}SM$`)
+	rawVisMeter = regexp.MustCompile(`^\d{4}$`)
+	rawRVR      = regexp.MustCompile(`^R\d{2}[LRC]?/`)
+	rawWeather  = regexp.MustCompile(`^([-+])?(VC)?(MI|PR|BC|DR|BL|SH|TS|FZ)?((?:DZ|RA|SN|SG|IC|PL|GR|GS|UP|BR|FG|FU|VA|DU|SA|HZ|PY|PO|SQ|FC|SS|DS)*)$`)
+	rawCloud    = regexp.MustCompile(`^(FEW|SCT|BKN|OVC|VV)(\d{3}|///)(CB|TCU)?$`)
+	rawTemp     = regexp.MustCompile(`^(M?\d{2})/(M?\d{2})?$`)
+	rawTempRMK  = regexp.MustCompile(`^T([01])(\d{3})([01])(\d{3})$`)
+	rawAltim    = regexp.MustCompile(`^([AQ])(\d{4})$`)
+	rawPeriod   = regexp.MustCompile(`^\d{4}/\d{4}$`)
+)
+
+const (
+	metersPerSM = 1609.344
+	hPaToInHg   = 0.02953
+)
+
+// conditions are the groups METARs and TAF periods share
+type conditions struct {
+	wind   *types.WindData
+	vis    types.Mi
+	wx     []string
+	clouds []types.CloudData
+}
+
+// take decodes the shared group starting at tokens[i], returning how many
+// tokens it used, or 0 when tokens[i] isn't one of them
+func (c *conditions) take(tokens []string, i int) int {
+	tok := tokens[i]
+	switch {
+	case rawWind.MatchString(tok):
+		c.wind = decodeWind(rawWind.FindStringSubmatch(tok))
+	case rawWindVar.MatchString(tok), rawRVR.MatchString(tok):
+		// variable direction range and runway visual range: not kept
+	case tok == "CAVOK":
+		c.vis = 99
+		c.clouds = append(c.clouds, types.CloudData{Coverage: "CLR"})
+	case rawVisWhole.MatchString(tok) && i+1 < len(tokens) && rawVisFrac.MatchString(tokens[i+1]):
+		whole, _ := strconv.Atoi(tok)
+		c.vis = types.Mi(float64(whole) + visSM(tokens[i+1]))
+		return 2
+	case rawVisSM.MatchString(tok):
+		c.vis = types.Mi(visSM(tok))
+	case rawVisMeter.MatchString(tok):
+		meters, _ := strconv.Atoi(tok)
+		if meters >= 9999 {
+			c.vis = 99
+		} else {
+			c.vis = types.Mi(float64(meters) / metersPerSM)
+		}
+	case tok == "CLR" || tok == "SKC" || tok == "NSC" || tok == "NCD":
+		c.clouds = append(c.clouds, types.CloudData{Coverage: tok})
+	case rawCloud.MatchString(tok):
+		m := rawCloud.FindStringSubmatch(tok)
+		hundreds, _ := strconv.Atoi(m[2])
+		c.clouds = append(c.clouds, types.CloudData{Coverage: m[1], Base: types.Feet(hundreds * 100)})
+	case isWeather(tok):
+		c.wx = append(c.wx, tok)
+	default:
+		return 0
+	}
+	return 1
+}
+
+func decodeWind(m []string) *types.WindData {
+	speed, _ := strconv.Atoi(m[2])
+	w := &types.WindData{Speed: types.Knots(speed)}
+	if m[3] != "" {
+		gusts, _ := strconv.Atoi(m[3])
+		g := types.Knots(gusts)
+		w.Gusts = &g
+	}
+	if m[4] == "MPS" {
+		w.Speed = types.Knots(float64(w.Speed)*1.944 + 0.5)
+		if w.Gusts != nil {
+			*w.Gusts = types.Knots(float64(*w.Gusts)*1.944 + 0.5)
+		}
+	}
+	if m[1] == "VRB" {
+		w.Variable = true
+	} else {
+		dir, _ := strconv.Atoi(m[1])
+		w.Direction = types.DegMag(dir)
+	}
+	w.Calm = w.Speed == 0 && w.Direction == 0 && !w.Variable
+	return w
+}
+
+// visSM reads "10SM", "1/2SM", "M1/4SM" or "P6SM"; P6SM reads as 99
+func visSM(tok string) float64 {
+	m := rawVisSM.FindStringSubmatch(tok)
+	if m == nil {
+		return 0
+	}
+	if m[1] == "P" {
+		return 99
+	}
+	num, _ := strconv.ParseFloat(m[2], 64)
+	if m[3] != "" {
+		den, _ := strconv.ParseFloat(m[3], 64)
+		if den > 0 {
+			num /= den
+		}
+	}
+	return num
+}
+
+func isWeather(tok string) bool {
+	m := rawWeather.FindStringSubmatch(tok)
+	return m != nil && (m[3] != "" || m[4] != "")
+}
+
+// dayTime places a day/hour/minute group in the month around ref; reports
+// from late last month or valid into next month land correctly
+func dayTime(ref time.Time, day, hour, minute int) time.Time {
+	ref = ref.UTC()
+	t := time.Date(ref.Year(), ref.Month(), day, hour, minute, 0, 0, time.UTC)
+	switch {
+	case t.Sub(ref) > 15*24*time.Hour:
+		t = time.Date(ref.Year(), ref.Month()-1, day, hour, minute, 0, 0, time.UTC)
+	case ref.Sub(t) > 15*24*time.Hour:
+		t = time.Date(ref.Year(), ref.Month()+1, day, hour, minute, 0, 0, time.UTC)
+	}
+	return t
+}
+
+// Detect tells a raw METAR, SPECI, TAF or PIREP apart by its leading tokens,
+// returning "" when it's none of them
+func Detect(raw string) string {
+	tokens := strings.Fields(raw)
+	if len(tokens) == 0 {
+		return ""
+	}
+	switch tokens[0] {
+	case "METAR", "SPECI", "TAF":
+		return tokens[0]
+	}
+	if strings.Contains(raw, "/OV") || tokens[0] == "UA" || tokens[0] == "UUA" ||
+		(len(tokens) > 1 && (tokens[1] == "UA" || tokens[1] == "UUA")) {
+		return "PIREP"
+	}
+	if len(tokens) > 2 && rawDayTime.MatchString(tokens[1]) {
+		if rawPeriod.MatchString(tokens[2]) {
+			return "TAF"
+		}
+		return "METAR"
+	}
+	if len(tokens) > 1 && rawPeriod.MatchString(tokens[1]) {
+		return "TAF" // no issue time
+	}
+	return ""
+}
+
+// FlightCategory works out VFR, MVFR, IFR or LIFR from visibility and the
+// lowest broken or overcast layer, or "" when the report gives neither
+func FlightCategory(vis types.Mi, clouds []types.CloudData) string {
+	if vis <= 0 && len(clouds) == 0 {
+		return ""
+	}
+	ceiling := types.Feet(1 << 30)
+	for _, l := range clouds {
+		if l.Coverage == "BKN" || l.Coverage == "OVC" || l.Coverage == "VV" {
+			ceiling = min(ceiling, l.Base)
+		}
+	}
+	hasVis := vis > 0
+	switch {
+	case ceiling < 500 || (hasVis && vis < 1):
+		return "LIFR"
+	case ceiling < 1000 || (hasVis && vis < 3):
+		return "IFR"
+	case ceiling <= 3000 || (hasVis && vis <= 5):
+		return "MVFR"
+	}
+	return "VFR"
+}
+
+// DecodeMETAR decodes a raw METAR or SPECI and returns it with its station.
+// ref anchors the day-of-month timestamp, normally the time it was received.
+func DecodeMETAR(raw string, ref time.Time) (types.METAR, string, error) {
+	raw = strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimSpace(raw), "=")), " ")
+	tokens := strings.Fields(raw)
+	m := types.METAR{RawOb: raw, Type: "METAR"}
+
+	i := 0
+	if i < len(tokens) && (tokens[i] == "METAR" || tokens[i] == "SPECI") {
+		m.Type = tokens[i]
+		i++
+	}
+	if i >= len(tokens) || !rawStation.MatchString(tokens[i]) {
+		return m, "", errors.New("METAR: missing station identifier")
+	}
+	station := tokens[i]
+	i++
+	if i >= len(tokens) || !rawDayTime.MatchString(tokens[i]) {
+		return m, station, errors.New("METAR: missing observation time")
+	}
+	dt := rawDayTime.FindStringSubmatch(tokens[i])
+	day, _ := strconv.Atoi(dt[1])
+	hour, _ := strconv.Atoi(dt[2])
+	minute, _ := strconv.Atoi(dt[3])
+	obs := dayTime(ref, day, hour, minute)
+	m.Reported.Epoch = obs.Unix()
+	m.Reported.Age = int(ref.Sub(obs).Minutes())
+	m.Reported.Zulu = types.Time{Day: uint8(obs.Day()), Hour: uint8(obs.Hour())}
+	local := obs.Local()
+	m.Reported.Local = types.Time{Day: uint8(local.Day()), Hour: uint8(local.Hour())}
+	i++
+
+	var c conditions
+	for ; i < len(tokens) && tokens[i] != "RMK"; i++ {
+		tok := tokens[i]
+		if tok == "AUTO" || tok == "COR" {
+			continue
+		}
+		if n := c.take(tokens, i); n > 0 {
+			i += n - 1
+			continue
+		}
+		switch {
+		case rawTemp.MatchString(tok):
+			t := rawTemp.FindStringSubmatch(tok)
+			m.Temp.Ambient = signedTemp(t[1])
+			m.Temp.AmbientExact = float64(m.Temp.Ambient)
+			if t[2] != "" {
+				m.Temp.Dewpoint = signedTemp(t[2])
+				m.Temp.DewpointExact = float64(m.Temp.Dewpoint)
+			}
+		case rawAltim.MatchString(tok):
+			a := rawAltim.FindStringSubmatch(tok)
+			v, _ := strconv.ParseFloat(a[2], 64)
+			if a[1] == "A" {
+				m.Altimeter = types.InHg(v / 100)
+			} else {
+				m.Altimeter = types.InHg(math2(v * hPaToInHg))
+			}
+		}
+	}
+
+	if c.wind != nil {
+		m.Wind = *c.wind
+	}
+	m.Visibility = c.vis
+	m.WxString = strings.Join(c.wx, " ")
+	m.Clouds = c.clouds
+	if m.Clouds == nil {
+		m.Clouds = make([]types.CloudData, 0)
+	}
+	m.FltCat = FlightCategory(m.Visibility, m.Clouds)
+
+	// the T group in remarks carries tenths of a degree
+	for _, tok := range tokens[i:] {
+		if t := rawTempRMK.FindStringSubmatch(tok); t != nil {
+			m.Temp.AmbientExact = tenths(t[1], t[2])
+			m.Temp.DewpointExact = tenths(t[3], t[4])
+		}
+	}
+	loadRemarks(&ParseContext{tokens: tokens, output: &m})
+	return m, station, nil
+}
+
+func signedTemp(s string) int {
+	neg := strings.HasPrefix(s, "M")
+	v, _ := strconv.Atoi(strings.TrimPrefix(s, "M"))
+	if neg {
+		return -v
+	}
+	return v
+}
+
+func tenths(sign, digits string) float64 {
+	v, _ := strconv.Atoi(digits)
+	if sign == "1" {
+		v = -v
+	}
+	return float64(v) / 10
+}
+
+// math2 rounds to two decimal places
+func math2(v float64) float64 {
+	return float64(int(v*100+0.5)) / 100
+}
+
+// DecodeTAF decodes a raw TAF into its base forecast and change groups. ref
+// anchors the day-of-month times, normally the time it was received.
+func DecodeTAF(raw string, ref time.Time) (types.TAF, error) {
+	raw = strings.Join(strings.Fields(strings.TrimSuffix(strings.TrimSpace(raw), "=")), " ")
+	tokens := strings.Fields(raw)
+	taf := types.TAF{Raw: raw}
+
+	i := 0
+	if i < len(tokens) && tokens[i] == "TAF" {
+		i++
+	}
+	for ; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "AMD":
+			taf.Amended = true
+			continue
+		case "COR":
+			taf.Corrected = true
+			continue
+		}
+		break
+	}
+	if i >= len(tokens) || !rawStation.MatchString(tokens[i]) {
+		return taf, errors.New("TAF: missing station identifier")
+	}
+	taf.Station = tokens[i]
+	i++
+
+	if i < len(tokens) && rawDayTime.MatchString(tokens[i]) {
+		dt := rawDayTime.FindStringSubmatch(tokens[i])
+		day, _ := strconv.Atoi(dt[1])
+		hour, _ := strconv.Atoi(dt[2])
+		minute, _ := strconv.Atoi(dt[3])
+		ref = dayTime(ref, day, hour, minute)
+		taf.Issued = ref.Unix()
+		i++
+	}
+	if i >= len(tokens) {
+		return taf, errors.New("TAF: missing valid period")
+	}
+	from, to, ok := tafPeriod(tokens[i], ref)
+	if !ok {
+		return taf, fmt.Errorf("TAF: bad valid period %q", tokens[i])
+	}
+	taf.ValidFrom, taf.ValidTo = from, to
+	i++
+
+	group := types.TAFGroup{Change: "BASE", From: from, To: to}
+	var c conditions
+	start := i
+	flush := func(end int) {
+		group.Wind = c.wind
+		group.Visibility = c.vis
+		group.WxString = strings.Join(c.wx, " ")
+		group.Clouds = c.clouds
+		group.Raw = strings.Join(tokens[start:end], " ")
+		taf.Groups = append(taf.Groups, group)
+		c = conditions{}
+	}
+
+	for ; i < len(tokens) && tokens[i] != "RMK"; i++ {
+		tok := tokens[i]
+
+		// a change group starts here
+		next := types.TAFGroup{}
+		opened := i
+		switch {
+		case len(tok) == 8 && strings.HasPrefix(tok, "FM"):
+			day, err1 := strconv.Atoi(tok[2:4])
+			hour, err2 := strconv.Atoi(tok[4:6])
+			minute, err3 := strconv.Atoi(tok[6:8])
+			if err1 != nil || err2 != nil || err3 != nil {
+				break
+			}
+			next = types.TAFGroup{Change: "FM", From: dayTime(ref, day, hour, minute).Unix(), To: to}
+		case tok == "BECMG" || tok == "TEMPO" || strings.HasPrefix(tok, "PROB"):
+			next.Change = tok
+			if strings.HasPrefix(tok, "PROB") && i+1 < len(tokens) && tokens[i+1] == "TEMPO" {
+				next.Change += " TEMPO"
+				i++
+			}
+			if i+1 < len(tokens) {
+				if s, e, ok := tafPeriod(tokens[i+1], ref); ok {
+					next.From, next.To = s, e
+					i++
+				}
+			}
+		}
+		if next.Change != "" {
+			flush(opened)
+			if next.Change == "FM" {
+				// an FM group ends the prevailing one before it
+				for j := len(taf.Groups) - 1; j >= 0; j-- {
+					if taf.Groups[j].Change == "FM" || taf.Groups[j].Change == "BASE" {
+						taf.Groups[j].To = next.From
+						break
+					}
+				}
+			}
+			group, start = next, opened
+			continue
+		}
+
+		if strings.HasPrefix(tok, "WS") {
+			group.WindShear = tok
+			continue
+		}
+		if n := c.take(tokens, i); n > 1 {
+			i += n - 1
+		}
+	}
+	flush(i)
+	return taf, nil
+}
+
+// tafPeriod reads a DDHH/DDHH validity; hour 24 means midnight
+func tafPeriod(tok string, ref time.Time) (int64, int64, bool) {
+	if len(tok) != 9 || tok[4] != '/' {
+		return 0, 0, false
+	}
+	nums := make([]int, 4)
+	for k, s := range []string{tok[0:2], tok[2:4], tok[5:7], tok[7:9]} {
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, 0, false
+		}
+		nums[k] = v
+	}
+	from := dayTime(ref, nums[0], nums[1], 0)
+	to := dayTime(ref, nums[2], nums[3], 0)
+	if to.Before(from) {
+		to = to.AddDate(0, 1, 0)
+	}
+	return from.Unix(), to.Unix(), true
+}
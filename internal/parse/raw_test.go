@@ -0,0 +1,317 @@
+package parse
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+var rawRef = time.Date(2026, 10, 19, 19, 0, 0, 0, time.UTC)
+
+func TestDetect(t *testing.T) {
+	tests := map[string]string{
+		"METAR KCGI 191853Z AUTO 00000KT 10SM CLR 12/08 A3012": "METAR",
+		"SPECI KCGI 191912Z 31012G20KT 3SM BR OVC009 11/10":    "SPECI",
+		"TAF KCGI 191720Z 1918/2018 20008KT P6SM SCT040":       "TAF",
+		"TAF AMD KCGI 191720Z 1918/2018 20008KT P6SM SCT040":   "TAF",
+		"KCGI 191853Z AUTO 00000KT 10SM CLR 12/08 A3012":       "METAR",
+		"KCGI 191720Z 1918/2018 20008KT P6SM SCT040":           "TAF",
+		"KCGI 1918/2018 20008KT P6SM SCT040":                   "TAF",
+		"CGI UA /OV CGI090010/TM 1850/FL050/TP C172/TB LGT":    "PIREP",
+		"UUA /OV STL/TM 1850/FL100/TP B737/TB SEV":             "PIREP",
+		"/OV PAH270020/TM 1902/FL080/TP PA28/SK BKN060-TOP075": "PIREP",
+		"hello world": "",
+		"":            "",
+	}
+	for raw, want := range tests {
+		if got := Detect(raw); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestDecodeMETAR(t *testing.T) {
+	gust := types.Knots(18)
+	tests := []struct {
+		name     string
+		raw      string
+		station  string
+		typ      string
+		reported time.Time
+		wind     types.WindData
+		vis      types.Mi
+		wx       string
+		clouds   int
+		temp     float64
+		dewpoint float64
+		altim    types.InHg
+		cat      string
+	}{
+		{
+			name:     "US with a mixed fraction",
+			raw:      "METAR KCGI 191853Z AUTO 31007G18KT 1 1/2SM -RA BR BKN008 OVC015 12/11 A2992 RMK AO2 T01220111",
+			station:  "KCGI",
+			typ:      "METAR",
+			reported: time.Date(2026, 10, 19, 18, 53, 0, 0, time.UTC),
+			wind:     types.WindData{Direction: 310, Speed: 7, Gusts: &gust},
+			vis:      1.5,
+			wx:       "-RA BR",
+			clouds:   2,
+			temp:     12.2,
+			dewpoint: 11.1,
+			altim:    29.92,
+			cat:      "IFR",
+		},
+		{
+			name:     "SPECI with quarter mile",
+			raw:      "SPECI KSTL 191912Z VRB04KT M1/4SM FG VV002 09/09 A3001=",
+			station:  "KSTL",
+			typ:      "SPECI",
+			reported: time.Date(2026, 10, 19, 19, 12, 0, 0, time.UTC),
+			wind:     types.WindData{Speed: 4, Variable: true},
+			vis:      0.25,
+			wx:       "FG",
+			clouds:   1,
+			temp:     9,
+			dewpoint: 9,
+			altim:    30.01,
+			cat:      "LIFR",
+		},
+		{
+			name:     "meters and hPa",
+			raw:      "EGLL 191850Z 24012KT 0800 FG VV002 08/08 Q1013",
+			station:  "EGLL",
+			typ:      "METAR",
+			reported: time.Date(2026, 10, 19, 18, 50, 0, 0, time.UTC),
+			wind:     types.WindData{Direction: 240, Speed: 12},
+			vis:      types.Mi(800 / metersPerSM),
+			wx:       "FG",
+			clouds:   1,
+			temp:     8,
+			dewpoint: 8,
+			altim:    29.91,
+			cat:      "LIFR",
+		},
+		{
+			name:     "CAVOK",
+			raw:      "LFPG 191830Z 27008KT CAVOK 14/M01 Q1021",
+			station:  "LFPG",
+			typ:      "METAR",
+			reported: time.Date(2026, 10, 19, 18, 30, 0, 0, time.UTC),
+			wind:     types.WindData{Direction: 270, Speed: 8},
+			vis:      99,
+			clouds:   1,
+			temp:     14,
+			dewpoint: -1,
+			altim:    30.15,
+			cat:      "VFR",
+		},
+		{
+			name:     "9999 meters",
+			raw:      "EDDF 191850Z 00000KT 9999 SCT025 10/06 Q1018",
+			station:  "EDDF",
+			typ:      "METAR",
+			reported: time.Date(2026, 10, 19, 18, 50, 0, 0, time.UTC),
+			wind:     types.WindData{Calm: true},
+			vis:      99,
+			clouds:   1,
+			temp:     10,
+			dewpoint: 6,
+			altim:    30.06,
+			cat:      "VFR",
+		},
+		{
+			name:     "nothing to categorise",
+			raw:      "KABC 191200Z",
+			station:  "KABC",
+			typ:      "METAR",
+			reported: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+			cat:      "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, station, err := DecodeMETAR(tt.raw, rawRef)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if station != tt.station || m.Type != tt.typ {
+				t.Errorf("station %q type %q, want %q %q", station, m.Type, tt.station, tt.typ)
+			}
+			if got := time.Unix(m.Reported.Epoch, 0).UTC(); !got.Equal(tt.reported) {
+				t.Errorf("reported %v, want %v", got, tt.reported)
+			}
+			w := m.Wind
+			if w.Direction != tt.wind.Direction || w.Speed != tt.wind.Speed || w.Variable != tt.wind.Variable ||
+				w.Calm != tt.wind.Calm || !equalPtr(w.Gusts, tt.wind.Gusts) {
+				t.Errorf("wind %+v, want %+v", w, tt.wind)
+			}
+			if math.Abs(float64(m.Visibility-tt.vis)) > 0.001 {
+				t.Errorf("visibility %v, want %v", m.Visibility, tt.vis)
+			}
+			if m.WxString != tt.wx {
+				t.Errorf("wx %q, want %q", m.WxString, tt.wx)
+			}
+			if len(m.Clouds) != tt.clouds {
+				t.Errorf("clouds %+v, want %d layers", m.Clouds, tt.clouds)
+			}
+			if m.Temp.AmbientExact != tt.temp || m.Temp.DewpointExact != tt.dewpoint {
+				t.Errorf("temp %v/%v, want %v/%v", m.Temp.AmbientExact, m.Temp.DewpointExact, tt.temp, tt.dewpoint)
+			}
+			if math.Abs(float64(m.Altimeter-tt.altim)) > 0.001 {
+				t.Errorf("altimeter %v, want %v", m.Altimeter, tt.altim)
+			}
+			if m.FltCat != tt.cat {
+				t.Errorf("category %q, want %q", m.FltCat, tt.cat)
+			}
+		})
+	}
+}
+
+func TestDecodeMETARErrors(t *testing.T) {
+	for _, raw := range []string{"", "191853Z 00000KT", "KCGI", "KCGI 00000KT 10SM"} {
+		if _, _, err := DecodeMETAR(raw, rawRef); err == nil {
+			t.Errorf("DecodeMETAR(%q): want an error", raw)
+		}
+	}
+}
+
+func TestDayTime(t *testing.T) {
+	tests := []struct {
+		ref               time.Time
+		day, hour, minute int
+		want              time.Time
+	}{
+		// same month
+		{rawRef, 19, 18, 53, time.Date(2026, 10, 19, 18, 53, 0, 0, time.UTC)},
+		// late last month, read early in this one
+		{time.Date(2026, 11, 1, 2, 0, 0, 0, time.UTC), 31, 23, 53, time.Date(2026, 10, 31, 23, 53, 0, 0, time.UTC)},
+		{time.Date(2026, 3, 1, 1, 0, 0, 0, time.UTC), 28, 23, 0, time.Date(2026, 2, 28, 23, 0, 0, 0, time.UTC)},
+		// valid into next month
+		{time.Date(2026, 10, 31, 22, 0, 0, 0, time.UTC), 1, 6, 0, time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC)},
+		{time.Date(2026, 12, 31, 20, 0, 0, 0, time.UTC), 1, 12, 0, time.Date(2027, 1, 1, 12, 0, 0, 0, time.UTC)},
+		// hour 24 is midnight
+		{rawRef, 19, 24, 0, time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := dayTime(tt.ref, tt.day, tt.hour, tt.minute); !got.Equal(tt.want) {
+			t.Errorf("dayTime(%v, %02d%02d%02d) = %v, want %v", tt.ref, tt.day, tt.hour, tt.minute, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeTAF(t *testing.T) {
+	raw := `TAF AMD KCGI 191720Z 1918/2018 20008KT P6SM SCT040
+		FM200200 VRB03KT P6SM SKC
+		BECMG 2008/2010 18010G20KT 5SM BR BKN015
+		TEMPO 2012/2016 2SM -TSRA OVC008CB
+		PROB30 TEMPO 2014/2018 1/2SM +TSRA VV003`
+	taf, err := DecodeTAF(raw, rawRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taf.Station != "KCGI" || !taf.Amended {
+		t.Errorf("station %q amended %v", taf.Station, taf.Amended)
+	}
+	at := func(day, hour int) int64 { return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC).Unix() }
+	if taf.Issued != time.Date(2026, 10, 19, 17, 20, 0, 0, time.UTC).Unix() {
+		t.Errorf("issued %v", time.Unix(taf.Issued, 0).UTC())
+	}
+	if taf.ValidFrom != at(19, 18) || taf.ValidTo != at(20, 18) {
+		t.Errorf("valid %v - %v", time.Unix(taf.ValidFrom, 0).UTC(), time.Unix(taf.ValidTo, 0).UTC())
+	}
+
+	want := []struct {
+		change   string
+		from, to int64
+		vis      types.Mi
+		wx       string
+		raw      string
+	}{
+		{"BASE", at(19, 18), time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC).Unix(), 99, "", "20008KT P6SM SCT040"},
+		{"FM", time.Date(2026, 10, 20, 2, 0, 0, 0, time.UTC).Unix(), at(20, 18), 99, "", "FM200200 VRB03KT P6SM SKC"},
+		{"BECMG", at(20, 8), at(20, 10), 5, "BR", "BECMG 2008/2010 18010G20KT 5SM BR BKN015"},
+		{"TEMPO", at(20, 12), at(20, 16), 2, "-TSRA", "TEMPO 2012/2016 2SM -TSRA OVC008CB"},
+		{"PROB30 TEMPO", at(20, 14), at(20, 18), 0.5, "+TSRA", "PROB30 TEMPO 2014/2018 1/2SM +TSRA VV003"},
+	}
+	if len(taf.Groups) != len(want) {
+		t.Fatalf("got %d groups, want %d: %+v", len(taf.Groups), len(want), taf.Groups)
+	}
+	for i, w := range want {
+		g := taf.Groups[i]
+		if g.Change != w.change || g.From != w.from || g.To != w.to {
+			t.Errorf("group %d: %s %v-%v, want %s %v-%v", i, g.Change,
+				time.Unix(g.From, 0).UTC(), time.Unix(g.To, 0).UTC(),
+				w.change, time.Unix(w.from, 0).UTC(), time.Unix(w.to, 0).UTC())
+		}
+		if g.Visibility != w.vis || g.WxString != w.wx {
+			t.Errorf("group %d: vis %v wx %q, want %v %q", i, g.Visibility, g.WxString, w.vis, w.wx)
+		}
+		if g.Raw != w.raw {
+			t.Errorf("group %d raw %q, want %q", i, g.Raw, w.raw)
+		}
+	}
+	if w := taf.Groups[2].Wind; w == nil || w.Direction != 180 || w.Gusts == nil || *w.Gusts != 20 {
+		t.Errorf("BECMG wind %+v", w)
+	}
+	if c := taf.Groups[3].Clouds; len(c) != 1 || c[0].Coverage != "OVC" || c[0].Base != 800 {
+		t.Errorf("TEMPO clouds %+v", c)
+	}
+}
+
+func TestDecodeTAFMonthRollover(t *testing.T) {
+	ref := time.Date(2026, 10, 31, 18, 0, 0, 0, time.UTC)
+	taf, err := DecodeTAF("KXYZ 311720Z 3118/0124 24010KT 9999 FEW030 FM010600 27015KT CAVOK", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := time.Unix(taf.ValidTo, 0).UTC(); !got.Equal(time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("valid to %v, want 2 Nov 00Z", got)
+	}
+	if len(taf.Groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(taf.Groups))
+	}
+	fm := taf.Groups[1]
+	if got := time.Unix(fm.From, 0).UTC(); !got.Equal(time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("FM from %v, want 1 Nov 06Z", got)
+	}
+	if fm.Visibility != 99 || len(fm.Clouds) != 1 || fm.Clouds[0].Coverage != "CLR" {
+		t.Errorf("CAVOK group vis %v clouds %+v", fm.Visibility, fm.Clouds)
+	}
+}
+
+func TestDecodeTAFErrors(t *testing.T) {
+	for _, raw := range []string{"TAF", "TAF KCGI", "TAF KCGI 191720Z", "TAF KCGI 191720Z 1918-2018"} {
+		if _, err := DecodeTAF(raw, rawRef); err == nil {
+			t.Errorf("DecodeTAF(%q): want an error", raw)
+		}
+	}
+}
+
+func TestFlightCategory(t *testing.T) {
+	ovc := func(base types.Feet) []types.CloudData { return []types.CloudData{{Coverage: "OVC", Base: base}} }
+	tests := []struct {
+		vis    types.Mi
+		clouds []types.CloudData
+		want   string
+	}{
+		{0, nil, ""},
+		{10, nil, "VFR"},
+		{0, ovc(2000), "MVFR"}, // ceiling alone
+		{10, []types.CloudData{{Coverage: "SCT", Base: 400}}, "VFR"},
+		{4, ovc(5000), "MVFR"},
+		{10, ovc(3000), "MVFR"},
+		{2, ovc(5000), "IFR"},
+		{10, ovc(900), "IFR"},
+		{0.5, ovc(5000), "LIFR"},
+		{10, []types.CloudData{{Coverage: "VV", Base: 200}}, "LIFR"},
+	}
+	for _, tt := range tests {
+		if got := FlightCategory(tt.vis, tt.clouds); got != tt.want {
+			t.Errorf("FlightCategory(%v, %+v) = %q, want %q", tt.vis, tt.clouds, got, tt.want)
+		}
+	}
+}
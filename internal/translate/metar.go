@@ -0,0 +1,156 @@
+package translate
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+var coverNames = map[string]string{
+	"FEW": "few clouds",
+	"SCT": "scattered clouds",
+	"BKN": "broken clouds",
+	"OVC": "overcast",
+}
+
+// METAR renders a decoded observation as plain sentences, e.g.
+// "Observed at 18:53Z. Wind 310 at 7 knots, visibility 10 miles, few clouds
+// at 25,000 feet, temperature 14°C, dewpoint 9°C, altimeter 30.02. Conditions
+// are VFR."
+func METAR(m types.METAR) string {
+	var sentences []string
+
+	if m.Reported.Epoch > 0 {
+		kind := "Observed"
+		if m.Type == "SPECI" {
+			kind = "Special observation"
+		}
+		at := time.Unix(m.Reported.Epoch, 0).UTC().Format("15:04Z")
+		sentences = append(sentences, fmt.Sprintf("%s at %s.", kind, at))
+	}
+
+	clauses := []string{Wind(m.Wind)}
+	if vis := Visibility(m.Visibility); vis != "" {
+		clauses = append(clauses, vis)
+	}
+	if wx := Weather(m.WxString); wx != "" {
+		clauses = append(clauses, wx)
+	}
+	clauses = append(clauses, Clouds(m.Clouds))
+	if m.RawOb != "" {
+		clauses = append(clauses,
+			fmt.Sprintf("temperature %d°C", m.Temp.Ambient),
+			fmt.Sprintf("dewpoint %d°C", m.Temp.Dewpoint))
+	}
+	if m.Altimeter > 0 {
+		clauses = append(clauses, fmt.Sprintf("altimeter %.2f", float64(m.Altimeter)))
+	}
+	sentences = append(sentences, capitalize(strings.Join(clauses, ", "))+".")
+
+	if m.FltCat != "" {
+		sentences = append(sentences, fmt.Sprintf("Conditions are %s.", m.FltCat))
+	}
+	return strings.Join(sentences, " ")
+}
+
+// Wind describes a wind group, e.g. "wind 310 at 7 knots gusting 18"
+func Wind(w types.WindData) string {
+	switch {
+	case w.Calm:
+		return "wind calm"
+	case w.Variable:
+		return fmt.Sprintf("wind variable at %s", knots(w.Speed)) + gusting(w.Gusts)
+	}
+	return fmt.Sprintf("wind %03d at %s", w.Direction, knots(w.Speed)) + gusting(w.Gusts)
+}
+
+func knots(k types.Knots) string {
+	if k == 1 {
+		return "1 knot"
+	}
+	return fmt.Sprintf("%d knots", k)
+}
+
+func gusting(g *types.Knots) string {
+	if g == nil {
+		return ""
+	}
+	return fmt.Sprintf(" gusting %d", *g)
+}
+
+// Visibility describes statute miles, e.g. "visibility 1 1/2 miles"
+func Visibility(v types.Mi) string {
+	switch {
+	case v <= 0:
+		return ""
+	case v >= 99:
+		return "visibility 10 miles or more"
+	case v == 1:
+		return "visibility 1 mile"
+	case v < 1:
+		return fmt.Sprintf("visibility %s mile", fraction(float64(v)))
+	}
+	return fmt.Sprintf("visibility %s miles", fraction(float64(v)))
+}
+
+// fraction writes miles to the nearest sixteenth, e.g. 2.5 as "2 1/2"
+func fraction(v float64) string {
+	whole := math.Floor(v)
+	num := int(math.Round((v - whole) * 16))
+	if num == 16 {
+		whole, num = whole+1, 0
+	}
+	if num == 0 {
+		return fmt.Sprintf("%.0f", whole)
+	}
+	den := 16
+	for num%2 == 0 {
+		num, den = num/2, den/2
+	}
+	if whole == 0 {
+		return fmt.Sprintf("%d/%d", num, den)
+	}
+	return fmt.Sprintf("%.0f %d/%d", whole, num, den)
+}
+
+// Clouds describes the layers from the bottom up, e.g. "few clouds at 2,500
+// feet, overcast at 8,000 feet"
+func Clouds(layers []types.CloudData) string {
+	var parts []string
+	for _, l := range layers {
+		switch l.Coverage {
+		case "CLR", "SKC", "NCD", "NSC", "CAVOK":
+			parts = append(parts, "sky clear")
+		case "VV", "OVX":
+			parts = append(parts, fmt.Sprintf("sky obscured, vertical visibility %s feet", thousands(int(l.Base))))
+		default:
+			name, ok := coverNames[l.Coverage]
+			if !ok {
+				name = l.Coverage
+			}
+			parts = append(parts, fmt.Sprintf("%s at %s feet", name, thousands(int(l.Base))))
+		}
+	}
+	if len(parts) == 0 {
+		return "sky clear"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func thousands(n int) string {
+	s := fmt.Sprintf("%d", n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
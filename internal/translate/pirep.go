@@ -0,0 +1,114 @@
+package translate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+var intensityNames = map[string]string{
+	"NEG":   "no",
+	"SMTH":  "smooth",
+	"LGT":   "light",
+	"MOD":   "moderate",
+	"SEV":   "severe",
+	"EXTRM": "extreme",
+	"TRC":   "trace",
+}
+
+// PIREP renders a decoded pilot report as plain sentences, e.g. "Pilot
+// report at 1745Z over OKC270015 from a C172 at 4,500 feet. Temperature
+// -2°C, occasional moderate chop from 4,000 to 6,000 feet."
+func PIREP(p types.PIREP) string {
+	kind := "Pilot report"
+	if p.Urgent {
+		kind = "Urgent pilot report"
+	}
+	head := kind
+	if p.Time != "" {
+		head += " at " + p.Time + "Z"
+	}
+	if p.Location != "" {
+		head += " over " + p.Location
+	}
+	if p.AircraftType != "" {
+		head += " from a " + p.AircraftType
+	}
+	if p.Altitude != nil {
+		head += fmt.Sprintf(" at %s feet", thousands(int(*p.Altitude)))
+	}
+	sentences := []string{head + "."}
+
+	var clauses []string
+	if p.Sky != "" {
+		clauses = append(clauses, "sky "+p.Sky)
+	}
+	if wx := Weather(p.Weather); wx != "" {
+		clauses = append(clauses, wx)
+	}
+	if p.Wind != nil {
+		clauses = append(clauses, Wind(*p.Wind))
+	}
+	if p.Temp != nil {
+		clauses = append(clauses, fmt.Sprintf("temperature %d°C", *p.Temp))
+	}
+	clauses = append(clauses, conditions(p.Turbulence, "turbulence")...)
+	clauses = append(clauses, conditions(p.Icing, "icing")...)
+	if len(clauses) > 0 {
+		sentences = append(sentences, capitalize(strings.Join(clauses, ", "))+".")
+	}
+	if p.Remarks != "" {
+		sentences = append(sentences, "Remarks: "+p.Remarks+".")
+	}
+	return strings.Join(sentences, " ")
+}
+
+// conditions describes /TB or /IC groups, e.g. "occasional moderate chop
+// from 4,000 to 6,000 feet"
+func conditions(cs []types.PIREPCondition, what string) []string {
+	var out []string
+	for _, c := range cs {
+		var words []string
+		switch c.Frequency {
+		case "OCNL":
+			words = append(words, "occasional")
+		case "INTMT":
+			words = append(words, "intermittent")
+		case "CONS":
+			words = append(words, "continuous")
+		}
+		var levels []string
+		for _, part := range strings.Split(c.Intensity, "-") {
+			if name, ok := intensityNames[part]; ok {
+				levels = append(levels, name)
+			} else if part != "" {
+				levels = append(levels, strings.ToLower(part))
+			}
+		}
+		if len(levels) > 0 {
+			words = append(words, strings.Join(levels, " to "))
+		}
+		switch c.Type {
+		case "":
+			words = append(words, what)
+		case "CHOP":
+			words = append(words, "chop")
+		case "CAT":
+			words = append(words, "clear air", what)
+		default:
+			words = append(words, strings.ToLower(c.Type), what)
+		}
+		s := strings.Join(words, " ")
+		switch {
+		case c.Base != nil && c.Top != nil:
+			s += fmt.Sprintf(" from %s to %s feet", thousands(int(*c.Base)), thousands(int(*c.Top)))
+		case c.Top != nil:
+			s += fmt.Sprintf(" below %s feet", thousands(int(*c.Top)))
+		case c.Base != nil:
+			s += fmt.Sprintf(" above %s feet", thousands(int(*c.Base)))
+		}
+		out = append(out, s)
+	}
+	return out
+}
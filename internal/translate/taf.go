@@ -0,0 +1,79 @@
+package translate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+var changeNames = map[string]string{
+	"FM":    "From",
+	"BECMG": "Becoming",
+	"TEMPO": "Temporarily",
+}
+
+// TAF renders a decoded forecast one line per period, e.g.
+// "Forecast for KCGI valid 19/18Z to 20/18Z." followed by
+// "From 19/22Z: wind 200 at 8 knots, visibility over 6 miles, sky clear."
+func TAF(t types.TAF) string {
+	head := "Forecast"
+	if t.Amended {
+		head = "Amended forecast"
+	}
+	lines := []string{fmt.Sprintf("%s for %s valid %s to %s.", head, t.Station, dayHour(t.ValidFrom), dayHour(t.ValidTo))}
+	for _, g := range t.Groups {
+		lines = append(lines, tafPeriod(g))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func tafPeriod(g types.TAFGroup) string {
+	var when string
+	switch {
+	case g.Change == "BASE":
+		when = "Initially"
+	case g.Change == "FM":
+		when = "From " + dayHour(g.From)
+	case strings.HasPrefix(g.Change, "PROB"):
+		chance := strings.TrimPrefix(strings.Fields(g.Change)[0], "PROB")
+		when = fmt.Sprintf("%s%% chance between %s and %s", chance, dayHour(g.From), dayHour(g.To))
+		if strings.HasSuffix(g.Change, "TEMPO") {
+			when += ", temporarily"
+		}
+	default:
+		name, ok := changeNames[g.Change]
+		if !ok {
+			name = g.Change
+		}
+		when = fmt.Sprintf("%s between %s and %s", name, dayHour(g.From), dayHour(g.To))
+	}
+
+	var clauses []string
+	if g.Wind != nil {
+		clauses = append(clauses, Wind(*g.Wind))
+	}
+	if g.Visibility >= 99 {
+		clauses = append(clauses, "visibility over 6 miles")
+	} else if vis := Visibility(g.Visibility); vis != "" {
+		clauses = append(clauses, vis)
+	}
+	if wx := Weather(g.WxString); wx != "" {
+		clauses = append(clauses, wx)
+	}
+	if len(g.Clouds) > 0 {
+		clauses = append(clauses, Clouds(g.Clouds))
+	}
+	if g.WindShear != "" {
+		clauses = append(clauses, "low-level wind shear")
+	}
+	if len(clauses) == 0 {
+		return when + "."
+	}
+	return when + ": " + strings.Join(clauses, ", ") + "."
+}
+
+func dayHour(epoch int64) string {
+	return time.Unix(epoch, 0).UTC().Format("02/15Z")
+}
@@ -0,0 +1,156 @@
+package translate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+func TestWeather(t *testing.T) {
+	tests := map[string]string{
+		"-TSRA BR":  "light thunderstorm with rain, mist",
+		"+FC":       "tornado or waterspout",
+		"FC":        "funnel cloud",
+		"VCSH":      "showers in the vicinity",
+		"VCTS":      "thunderstorm in the vicinity",
+		"FZFG":      "freezing fog",
+		"-FZDZ":     "light freezing drizzle",
+		"+SHRASN":   "heavy rain and snow showers",
+		"BLSN":      "blowing snow",
+		"-RA AO2":   "light rain",
+		"TS":        "thunderstorm",
+		"":          "",
+		"RMK SLP12": "",
+	}
+	for in, want := range tests {
+		if got := Weather(in); got != want {
+			t.Errorf("Weather(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestVisibility(t *testing.T) {
+	tests := map[types.Mi]string{
+		0:      "",
+		0.25:   "visibility 1/4 mile",
+		0.5:    "visibility 1/2 mile",
+		0.75:   "visibility 3/4 mile",
+		0.125:  "visibility 1/8 mile",
+		0.0625: "visibility 1/16 mile",
+		1:      "visibility 1 mile",
+		1.5:    "visibility 1 1/2 miles",
+		2.25:   "visibility 2 1/4 miles",
+		1.999:  "visibility 2 miles",
+		10:     "visibility 10 miles",
+		99:     "visibility 10 miles or more",
+	}
+	for in, want := range tests {
+		if got := Visibility(in); got != want {
+			t.Errorf("Visibility(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestClouds(t *testing.T) {
+	tests := []struct {
+		layers []types.CloudData
+		want   string
+	}{
+		{nil, "sky clear"},
+		{[]types.CloudData{{Coverage: "CLR"}}, "sky clear"},
+		{[]types.CloudData{{Coverage: "VV", Base: 200}}, "sky obscured, vertical visibility 200 feet"},
+		{[]types.CloudData{{Coverage: "FEW", Base: 2500}, {Coverage: "OVC", Base: 8000}}, "few clouds at 2,500 feet, overcast at 8,000 feet"},
+		{[]types.CloudData{{Coverage: "SCT", Base: 12000}, {Coverage: "BKN", Base: 25000}}, "scattered clouds at 12,000 feet, broken clouds at 25,000 feet"},
+	}
+	for _, tt := range tests {
+		if got := Clouds(tt.layers); got != tt.want {
+			t.Errorf("Clouds(%+v) = %q, want %q", tt.layers, got, tt.want)
+		}
+	}
+}
+
+func TestPIREP(t *testing.T) {
+	ft := func(f types.Feet) *types.Feet { return &f }
+	temp := -2
+	tests := []struct {
+		name string
+		p    types.PIREP
+		want string
+	}{
+		{"header only", types.PIREP{Time: "1745", Location: "OKC270015", AircraftType: "C172", Altitude: ft(4500)},
+			"Pilot report at 1745Z over OKC270015 from a C172 at 4,500 feet."},
+		{"urgent", types.PIREP{Urgent: true, Location: "CGI"}, "Urgent pilot report over CGI."},
+		{"sky, weather and temperature", types.PIREP{Sky: "BKN040", Weather: "-RA", Temp: &temp},
+			"Pilot report. Sky BKN040, light rain, temperature -2°C."},
+		{"chop with layer", types.PIREP{Turbulence: []types.PIREPCondition{{Frequency: "OCNL", Intensity: "MOD", Type: "CHOP", Base: ft(4000), Top: ft(6000)}}},
+			"Pilot report. Occasional moderate chop from 4,000 to 6,000 feet."},
+		{"clear air turbulence range", types.PIREP{Turbulence: []types.PIREPCondition{{Intensity: "LGT-MOD", Type: "CAT", Base: ft(35000)}}},
+			"Pilot report. Light to moderate clear air turbulence above 35,000 feet."},
+		{"untyped turbulence", types.PIREP{Turbulence: []types.PIREPCondition{{Frequency: "CONS", Intensity: "SEV"}}},
+			"Pilot report. Continuous severe turbulence."},
+		{"rime icing below top", types.PIREP{Icing: []types.PIREPCondition{{Intensity: "TRC", Type: "RIME", Top: ft(8000)}}},
+			"Pilot report. Trace rime icing below 8,000 feet."},
+		{"no icing", types.PIREP{Icing: []types.PIREPCondition{{Intensity: "NEG"}}}, "Pilot report. No icing."},
+		{"turbulence before icing", types.PIREP{
+			Turbulence: []types.PIREPCondition{{Intensity: "SMTH"}},
+			Icing:      []types.PIREPCondition{{Intensity: "MOD", Type: "MXD"}},
+		}, "Pilot report. Smooth turbulence, moderate mxd icing."},
+		{"remarks", types.PIREP{Remarks: "DURGC"}, "Pilot report. Remarks: DURGC."},
+	}
+	for _, tt := range tests {
+		if got := PIREP(tt.p); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTAFPeriod(t *testing.T) {
+	from := time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC).Unix()
+	to := time.Date(2026, 10, 19, 22, 0, 0, 0, time.UTC).Unix()
+	gust := types.Knots(25)
+	tests := []struct {
+		name string
+		g    types.TAFGroup
+		want string
+	}{
+		{"initially, P6SM", types.TAFGroup{Change: "BASE", Wind: &types.WindData{Direction: 200, Speed: 8}, Visibility: 99, Clouds: []types.CloudData{{Coverage: "SKC"}}},
+			"Initially: wind 200 at 8 knots, visibility over 6 miles, sky clear."},
+		{"from", types.TAFGroup{Change: "FM", From: from, Wind: &types.WindData{Direction: 270, Speed: 12, Gusts: &gust}},
+			"From 19/18Z: wind 270 at 12 knots gusting 25."},
+		{"tempo", types.TAFGroup{Change: "TEMPO", From: from, To: to, Visibility: 2, WxString: "-TSRA BR"},
+			"Temporarily between 19/18Z and 19/22Z: visibility 2 miles, light thunderstorm with rain, mist."},
+		{"becoming, shear", types.TAFGroup{Change: "BECMG", From: from, To: to, WindShear: "WS020/24040KT"},
+			"Becoming between 19/18Z and 19/22Z: low-level wind shear."},
+		{"probability", types.TAFGroup{Change: "PROB30", From: from, To: to, Visibility: 0.5, WxString: "FG"},
+			"30% chance between 19/18Z and 19/22Z: visibility 1/2 mile, fog."},
+		{"probability tempo", types.TAFGroup{Change: "PROB40 TEMPO", From: from, To: to, WxString: "TSRA"},
+			"40% chance between 19/18Z and 19/22Z, temporarily: thunderstorm with rain."},
+		{"empty", types.TAFGroup{Change: "TEMPO", From: from, To: to}, "Temporarily between 19/18Z and 19/22Z."},
+	}
+	for _, tt := range tests {
+		if got := tafPeriod(tt.g); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTAF(t *testing.T) {
+	from := time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC).Unix()
+	to := time.Date(2026, 10, 20, 18, 0, 0, 0, time.UTC).Unix()
+	taf := types.TAF{Station: "KCGI", ValidFrom: from, ValidTo: to, Groups: []types.TAFGroup{
+		{Change: "BASE", Visibility: 99},
+		{Change: "FM", From: from + 4*3600, Wind: &types.WindData{Calm: true}},
+	}}
+	want := "Forecast for KCGI valid 19/18Z to 20/18Z.\n" +
+		"Initially: visibility over 6 miles.\n" +
+		"From 19/22Z: wind calm."
+	if got := TAF(taf); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	taf.Amended, taf.Groups = true, nil
+	if got := TAF(taf); got != "Amended forecast for KCGI valid 19/18Z to 20/18Z." {
+		t.Errorf("amended: got %q", got)
+	}
+}
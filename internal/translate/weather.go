@@ -0,0 +1,102 @@
+package translate
+
+import "strings"
+
+var descriptors = map[string]string{
+	"MI": "shallow",
+	"PR": "partial",
+	"BC": "patches of",
+	"DR": "low drifting",
+	"BL": "blowing",
+	"FZ": "freezing",
+	// SH and TS are worded around the phenomena instead
+}
+
+var phenomena = map[string]string{
+	"DZ": "drizzle",
+	"RA": "rain",
+	"SN": "snow",
+	"SG": "snow grains",
+	"IC": "ice crystals",
+	"PL": "ice pellets",
+	"GR": "hail",
+	"GS": "small hail",
+	"UP": "unknown precipitation",
+	"BR": "mist",
+	"FG": "fog",
+	"FU": "smoke",
+	"VA": "volcanic ash",
+	"DU": "dust",
+	"SA": "sand",
+	"HZ": "haze",
+	"PY": "spray",
+	"PO": "dust whirls",
+	"SQ": "squalls",
+	"FC": "funnel cloud",
+	"SS": "sandstorm",
+	"DS": "duststorm",
+}
+
+// Weather describes a present-weather string such as "-TSRA BR" as
+// "light thunderstorm with rain, mist"
+func Weather(wx string) string {
+	var parts []string
+	for _, group := range strings.Fields(wx) {
+		if s := weatherGroup(group); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func weatherGroup(g string) string {
+	var intensity, vicinity string
+	switch {
+	case strings.HasPrefix(g, "-"):
+		intensity, g = "light", g[1:]
+	case strings.HasPrefix(g, "+"):
+		intensity, g = "heavy", g[1:]
+	}
+	if rest, ok := strings.CutPrefix(g, "VC"); ok {
+		vicinity, g = " in the vicinity", rest
+	}
+	if g == "FC" && intensity == "heavy" {
+		return "tornado or waterspout" + vicinity
+	}
+
+	var desc string
+	var names []string
+	for i := 0; i+2 <= len(g); i += 2 {
+		code := g[i : i+2]
+		switch {
+		case code == "SH" || code == "TS":
+			desc = code
+		case descriptors[code] != "":
+			desc = code
+		case phenomena[code] != "":
+			names = append(names, phenomena[code])
+		default:
+			return "" // not a weather group, e.g. a stray remark token
+		}
+	}
+	what := strings.Join(names, " and ")
+
+	var s string
+	switch desc {
+	case "TS":
+		s = "thunderstorm"
+		if what != "" {
+			s += " with " + what
+		}
+	case "SH":
+		s = strings.TrimSpace(what + " showers")
+	case "":
+		s = what
+	default:
+		s = strings.TrimSpace(descriptors[desc] + " " + what)
+	}
+	if intensity != "" {
+		s = intensity + " " + s
+	}
+	return s + vicinity
+}
@@ -0,0 +1,169 @@
+// Package pilotbar is the public face of the pilot-bar weather pipeline:
+// the same fetchers, decoders and cache the daemon uses, for other Go tools.
+package pilotbar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/internal/fetch"
+	"github.com/house-holder/pilot-bar/internal/parse"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// ErrNoData means the provider answered but has nothing for the station
+var ErrNoData = fetch.ErrNoData
+
+// Client fetches and decodes weather. The zero value isn't usable; build
+// one with New.
+type Client struct {
+	source      fetch.Source
+	cache       *cache.Store
+	maxAge      time.Duration
+	maxAttempts int
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithProvider points the client at an aviationweather.gov-compatible API,
+// e.g. "https://aviationweather.gov/api/data"
+func WithProvider(baseURL string) Option {
+	return func(c *Client) {
+		c.source.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHTTPClient replaces the default client, which times out after 10s
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.source.Client = hc
+	}
+}
+
+// WithCacheDir reads current conditions from a pilot-bar cache at dir when
+// they're younger than the max age, before going to the network
+func WithCacheDir(dir string) Option {
+	return func(c *Client) {
+		c.cache = &cache.Store{Dir: dir}
+	}
+}
+
+// WithMaxAge sets how old cached conditions may be and still be served;
+// the default is 10 minutes
+func WithMaxAge(d time.Duration) Option {
+	return func(c *Client) {
+		c.maxAge = d
+	}
+}
+
+// WithRetries sets how many attempts each fetch makes; the default is 3
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		c.maxAttempts = n
+	}
+}
+
+// New builds a Client
+func New(opts ...Option) *Client {
+	c := &Client{
+		maxAge:      10 * time.Minute,
+		maxAttempts: 3,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Current returns the station's latest observation, from the cache when one
+// is configured and fresh enough. A station that doesn't report borrows the
+// nearest METAR, as the daemon does, and says so in METARSub.
+func (c *Client) Current(ctx context.Context, icao string) (types.Airport, error) {
+	icao = strings.ToUpper(icao)
+	if c.cache != nil {
+		wx, err := c.cache.ReadStation(icao)
+		fetched := wx.Products[types.ProductMETAR].FetchedAt
+		if err == nil && wx.METAR.RawOb != "" && time.Since(time.Unix(fetched, 0)) < c.maxAge {
+			return wx, nil
+		}
+	}
+
+	resp, sub, err := c.source.GetMETARWithFallback(ctx, icao, c.maxAttempts)
+	if err != nil {
+		return types.Airport{}, fmt.Errorf("METAR for %s failed: %w", icao, err)
+	}
+	wx := types.Airport{
+		ICAO:      icao,
+		Name:      resp.Name,
+		Lat:       resp.Lat,
+		Lon:       resp.Long,
+		Elevation: parse.Elevation(resp.Elev),
+		METARSub:  sub,
+	}
+	if err := parse.BuildInternalMETAR(&resp, &wx.METAR); err != nil {
+		return types.Airport{}, fmt.Errorf("METAR for %s failed: %w", icao, err)
+	}
+	wx.METAR.Reported.Epoch = resp.ObsTime
+	return wx, nil
+}
+
+// Forecast returns the station's current TAF, decoded into periods
+func (c *Client) Forecast(ctx context.Context, icao string) (types.TAF, error) {
+	icao = strings.ToUpper(icao)
+	resp, err := c.source.GetTAF(ctx, icao, c.maxAttempts)
+	if err != nil {
+		return types.TAF{}, fmt.Errorf("TAF for %s failed: %w", icao, err)
+	}
+	return parse.DecodeTAF(resp.RawTAF, time.Now())
+}
+
+// Report is one decoded raw report; exactly one of METAR, TAF or PIREP is set
+type Report struct {
+	Kind    string       `json:"kind"` // METAR, SPECI, TAF or PIREP
+	Station string       `json:"station,omitempty"`
+	METAR   *types.METAR `json:"metar,omitempty"`
+	TAF     *types.TAF   `json:"taf,omitempty"`
+	PIREP   *types.PIREP `json:"pirep,omitempty"`
+}
+
+// Decode works out whether raw is a METAR, SPECI, TAF or PIREP and decodes
+// it. Day-of-month times are placed relative to now.
+func (c *Client) Decode(raw string) (Report, error) {
+	return Decode(raw, time.Now())
+}
+
+// Decode is Client.Decode with an explicit reference time, for reports
+// received in the past
+func Decode(raw string, ref time.Time) (Report, error) {
+	r := Report{Kind: parse.Detect(raw)}
+	switch r.Kind {
+	case "METAR", "SPECI":
+		m, station, err := parse.DecodeMETAR(raw, ref)
+		if err != nil {
+			return r, err
+		}
+		r.Kind, r.Station, r.METAR = m.Type, station, &m
+	case "TAF":
+		t, err := parse.DecodeTAF(raw, ref)
+		if err != nil {
+			return r, err
+		}
+		r.Station, r.TAF = t.Station, &t
+	case "PIREP":
+		p := parse.DecodePIREP(raw)
+		// a report may start with its station, or go straight to UA or /OV
+		if fields := strings.Fields(raw); fields[0] != "UA" && fields[0] != "UUA" && cache.ValidICAO(fields[0]) {
+			r.Station = strings.ToUpper(fields[0])
+		}
+		r.PIREP = &p
+	default:
+		return r, errors.New("not a METAR, SPECI, TAF or PIREP")
+	}
+	return r, nil
+}
@@ -0,0 +1,125 @@
+package pilotbar
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/house-holder/pilot-bar/internal/cache"
+	"github.com/house-holder/pilot-bar/pkg/types"
+)
+
+// metarServer answers every METAR request with a report named name
+func metarServer(t *testing.T, name string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"icaoId":"KCGI","name":%q,"rawOb":"KCGI 191353Z 18005KT 10SM CLR 20/10 A3000","obsTime":%d,"wdir":180,"wspd":5,"visib":10,"elev":100}]`,
+			name, time.Now().Unix())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClientsAreIndependent(t *testing.T) {
+	ctx := context.Background()
+	a := New(WithProvider(metarServer(t, "A").URL), WithRetries(1))
+	b := New(WithProvider(metarServer(t, "B").URL+"/"), WithRetries(1))
+
+	for want, c := range map[string]*Client{"A": a, "B": b} {
+		wx, err := c.Current(ctx, "kcgi")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wx.Name != want || wx.ICAO != "KCGI" {
+			t.Errorf("client %s got %q/%q", want, wx.ICAO, wx.Name)
+		}
+		if wx.Elevation != 328 {
+			t.Errorf("client %s elevation = %d ft, want 328 (100 m)", want, wx.Elevation)
+		}
+	}
+}
+
+func TestCurrentPrefersFreshCache(t *testing.T) {
+	ctx := context.Background()
+	store := cache.Store{Dir: t.TempDir()}
+	cached := types.Airport{
+		ICAO:     "KCGI",
+		Name:     "cached",
+		METAR:    types.METAR{RawOb: "KCGI 191353Z 18005KT 10SM CLR 20/10 A3000"},
+		Products: map[string]types.ProductState{types.ProductMETAR: {FetchedAt: time.Now().Unix()}},
+	}
+	if err := store.Write(cached); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := metarServer(t, "network")
+	withCache := New(WithProvider(srv.URL), WithCacheDir(store.Dir), WithRetries(1))
+	without := New(WithProvider(srv.URL), WithRetries(1))
+
+	if wx, err := withCache.Current(ctx, "KCGI"); err != nil || wx.Name != "cached" {
+		t.Errorf("with cache: %q, %v; want the cached entry", wx.Name, err)
+	}
+	// a cache dir on one client must not leak into another
+	if wx, err := without.Current(ctx, "KCGI"); err != nil || wx.Name != "network" {
+		t.Errorf("without cache: %q, %v; want the network report", wx.Name, err)
+	}
+}
+
+func TestCurrentFallsBackToNearby(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/metar" && r.URL.Query().Get("ids") == "K2W6":
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/airport":
+			fmt.Fprint(w, `[{"icaoId":"K2W6","name":"St Marys","lat":38.32,"lon":-76.55,"elev":43}]`)
+		case r.URL.Path == "/metar" && r.URL.Query().Has("bbox"):
+			fmt.Fprintf(w, `[{"icaoId":"KNHK","name":"Patuxent River","rawOb":"KNHK 191356Z 18005KT 10SM CLR 20/10 A3000","obsTime":%d,"visib":10,"lat":38.28,"lon":-76.41,"elev":12}]`,
+				time.Now().Unix())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	wx, err := New(WithProvider(srv.URL), WithRetries(1)).Current(context.Background(), "K2W6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wx.METARSub == nil || wx.METARSub.ICAO != "KNHK" {
+		t.Fatalf("METARSub = %+v, want KNHK", wx.METARSub)
+	}
+	// the weather is borrowed, the airport's identity isn't
+	if wx.ICAO != "K2W6" || wx.Name != "St Marys" || wx.Elevation != 141 {
+		t.Errorf("got %s %q elevation %d, want K2W6 \"St Marys\" 141", wx.ICAO, wx.Name, wx.Elevation)
+	}
+	if !strings.HasPrefix(wx.METAR.RawOb, "KNHK") {
+		t.Errorf("raw = %q, want the KNHK report", wx.METAR.RawOb)
+	}
+}
+
+func TestDecodePIREPStation(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{"DHT UA /OV DHT360015/TM 1547/FL080/TP C172", "DHT"},
+		{"KOKC UUA /OV OKC/TM 2010/FL100/TP B737/TB SEV", "KOKC"},
+		{"UA /OV ABC/TM 1200/FL050/TP C172", ""},
+		{"/OV DHT360015/TM 1547/FL080/TP C172", ""},
+		{"/OVDHT/TM 1547/FL080", ""},
+	}
+	ref := time.Date(2026, 10, 19, 16, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		r, err := Decode(tt.raw, ref)
+		if err != nil {
+			t.Errorf("Decode(%q): %v", tt.raw, err)
+			continue
+		}
+		if r.Kind != "PIREP" || r.Station != tt.want {
+			t.Errorf("Decode(%q) = %s station %q, want PIREP station %q", tt.raw, r.Kind, r.Station, tt.want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package types
+
+type AFDSection struct {
+	Name  string `json:"name"`  // normalized, e.g. "AVIATION"
+	Title string `json:"title"` // as issued, e.g. ".AVIATION /12Z TAFS/"
+	Body  string `json:"body"`
+}
+
+// main internal struct for an Area Forecast Discussion
+type AFD struct {
+	Office     string       `json:"office"`
+	Issued     string       `json:"issued"`
+	Forecaster string       `json:"forecaster"`
+	Sections   []AFDSection `json:"sections"`
+}
+
+// Section finds a section by normalized name
+func (a AFD) Section(name string) (AFDSection, bool) {
+	for _, s := range a.Sections {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return AFDSection{}, false
+}
@@ -1,12 +1,28 @@
 package types
 
 type Airport struct {
-	ICAO            string `json:"icao"`
-	Name            string `json:"name"`
-	CWA             string `json:"cwa"`
-	LastUpdateEpoch int64  `json:"last_update"`
-	Elevation       Feet   `json:"elevation"`
-	METAR           METAR  `json:"metar"`
-	RawTAF          string `json:"rawTAF"`
-	RawAFD          string `json:"rawAFD"`
+	ICAO            string      `json:"icao"`
+	Name            string      `json:"name"`
+	CWA             string      `json:"cwa"`
+	LastUpdateEpoch int64       `json:"last_update"`
+	Elevation       Feet        `json:"elevation"`
+	Lat             float64     `json:"lat"`
+	Lon             float64     `json:"lon"`
+	METAR           METAR       `json:"metar"`
+	METARSub        *Substitute `json:"metarSubstitute"`
+	RawTAF          string      `json:"rawTAF"`
+	TAFSub          *Substitute `json:"tafSubstitute"`
+	RawAFD          string      `json:"rawAFD"`
+	AFD             AFD         `json:"afd"`
+	PIREPs          []PIREP     `json:"pireps"`
+	Hazards         []Hazard    `json:"hazards"`
+	WindsAloft      WindsAloft  `json:"windsAloft"`
+
+	Products map[string]ProductState `json:"products"`
+}
+
+// Substitute records a nearby station reporting in place of one that doesn't
+type Substitute struct {
+	ICAO       string  `json:"icao"`
+	DistanceNM float64 `json:"distanceNM"`
 }
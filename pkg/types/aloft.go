@@ -0,0 +1,28 @@
+package types
+
+type StationInfo struct { // station metadata as returned by the API
+	IcaoID string  `json:"icaoId"`
+	FaaID  string  `json:"faaId"`
+	Site   string  `json:"site"`
+	Name   string  `json:"name"` // set by the airport endpoint instead of site
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Elev   int     `json:"elev"`
+}
+
+type AloftLevel struct {
+	Altitude Feet     `json:"altitude"`
+	Wind     WindData `json:"wind"` // Variable marks light and variable
+	Temp     *int     `json:"temp"`
+}
+
+// main internal struct, one FB forecast site
+type WindsAloft struct {
+	Station    string       `json:"station"`
+	Lat        float64      `json:"lat"`
+	Lon        float64      `json:"lon"`
+	DistanceNM float64      `json:"distanceNM"`
+	Valid      string       `json:"valid"`  // e.g. "181800Z"
+	ForUse     string       `json:"forUse"` // e.g. "1400-2100Z"
+	Levels     []AloftLevel `json:"levels"`
+}
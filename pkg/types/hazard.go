@@ -0,0 +1,80 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+type AirSigmetResponse struct { // domestic AIRMET/SIGMET as returned by the API
+	AirSigmetType string  `json:"airSigmetType"`
+	Hazard        string  `json:"hazard"`
+	Severity      any     `json:"severity"`
+	ValidTimeFrom int64   `json:"validTimeFrom"`
+	ValidTimeTo   int64   `json:"validTimeTo"`
+	AltitudeLow1  *int    `json:"altitudeLow1"`
+	AltitudeHi1   *int    `json:"altitudeHi1"`
+	RawAirSigmet  string  `json:"rawAirSigmet"`
+	Coords        []Coord `json:"coords"`
+}
+
+type GAIRMETresponse struct {
+	Tag          string  `json:"tag"`
+	ForecastHour int     `json:"forecastHour"`
+	ValidTime    string  `json:"validTime"`
+	Hazard       string  `json:"hazard"`
+	GeometryType string  `json:"geometryType"`
+	DueTo        string  `json:"due_to"`
+	Base         any     `json:"base"`
+	Top          any     `json:"top"`
+	Coords       []Coord `json:"coords"`
+}
+
+// Coord accepts lat/lon as either JSON numbers or strings; the G-AIRMET
+// endpoint sends strings. A missing or unreadable value decodes as NaN
+// rather than failing, so one bad vertex costs only its own area.
+type Coord struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+func (c *Coord) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Lat json.RawMessage `json:"lat"`
+		Lon json.RawMessage `json:"lon"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.Lat = flexFloat(raw.Lat)
+	c.Lon = flexFloat(raw.Lon)
+	return nil
+}
+
+func flexFloat(data json.RawMessage) float64 {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+		return math.NaN()
+	}
+	var f float64
+	if err := json.Unmarshal(data, &f); err != nil || len(data) == 0 || string(data) == "null" {
+		return math.NaN()
+	}
+	return f
+}
+
+// main internal struct
+type Hazard struct {
+	Product   string `json:"product"` // SIGMET, AIRMET, G-AIRMET
+	Kind      string `json:"kind"`    // IFR, MTN OBSC, TURB, ICE, LLWS, CONVECTIVE
+	Detail    string `json:"detail"`  // source hazard code or cause, e.g. TURB-HI
+	Severity  string `json:"severity"`
+	ValidFrom int64  `json:"validFrom"`
+	ValidTo   int64  `json:"validTo"`
+	Base      *Feet  `json:"base"`
+	Top       *Feet  `json:"top"`
+	Raw       string `json:"raw"`
+}
@@ -0,0 +1,46 @@
+package types
+
+// Observation is the compact form of a METAR kept in the history store
+type Observation struct {
+	Epoch      int64   `json:"t"`
+	Type       string  `json:"type,omitempty"` // METAR or SPECI
+	FltCat     string  `json:"cat,omitempty"`
+	WindDir    DegMag  `json:"wdir,omitempty"`
+	WindSpeed  Knots   `json:"wspd,omitempty"`
+	Gusts      Knots   `json:"gst,omitempty"`
+	Visibility Mi      `json:"vis"`
+	Ceiling    *Feet   `json:"cig,omitempty"` // lowest BKN/OVC layer
+	Temp       float64 `json:"temp"`
+	Dewpoint   float64 `json:"dewp"`
+	Altimeter  InHg    `json:"alt"`
+	WxString   string  `json:"wx,omitempty"`
+	RawOb      string  `json:"raw"`
+}
+
+// NewObservation condenses a decoded METAR
+func NewObservation(m METAR) Observation {
+	obs := Observation{
+		Epoch:      m.Reported.Epoch,
+		Type:       m.Type,
+		FltCat:     m.FltCat,
+		WindDir:    m.Wind.Direction,
+		WindSpeed:  m.Wind.Speed,
+		Visibility: m.Visibility,
+		Temp:       m.Temp.AmbientExact,
+		Dewpoint:   m.Temp.DewpointExact,
+		Altimeter:  m.Altimeter,
+		WxString:   m.WxString,
+		RawOb:      m.RawOb,
+	}
+	if m.Wind.Gusts != nil {
+		obs.Gusts = *m.Wind.Gusts
+	}
+	for _, layer := range m.Clouds {
+		if layer.Coverage == "BKN" || layer.Coverage == "OVC" || layer.Coverage == "VV" {
+			base := layer.Base
+			obs.Ceiling = &base
+			break
+		}
+	}
+	return obs
+}
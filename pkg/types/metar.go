@@ -40,7 +40,10 @@ type METARresponse struct { // the full data returned by the API
 }
 
 type TAFresponse struct {
-	RawTAF string `json:"rawTAF"`
+	IcaoID string  `json:"icaoId"`
+	RawTAF string  `json:"rawTAF"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
 }
 
 // component structs
@@ -67,6 +70,7 @@ type TempData struct {
 // main internal struct
 type METAR struct {
 	RawOb      string      `json:"rawOb"`
+	Type       string      `json:"type,omitempty"` // METAR or SPECI
 	Reported   Timestamp   `json:"reported"`
 	FltCat     string      `json:"fltCat"`
 	WxString   string      `json:"wxString"`
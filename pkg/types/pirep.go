@@ -0,0 +1,80 @@
+package types
+
+type PIREPresponse struct { // one report as returned by the API
+	ReceiptTime string   `json:"receiptTime"`
+	ObsTime     int64    `json:"obsTime"`
+	QcField     int      `json:"qcField"`
+	IcaoID      string   `json:"icaoId"`
+	AcType      string   `json:"acType"`
+	Lat         float64  `json:"lat"`
+	Lon         float64  `json:"lon"`
+	FltLvl      *int     `json:"fltLvl"`
+	FltLvlType  string   `json:"fltLvlType"`
+	Visib       any      `json:"visib"`
+	WxString    string   `json:"wxString"`
+	Temp        *float64 `json:"temp"`
+	Wdir        *float64 `json:"wdir"`
+	Wspd        *float64 `json:"wspd"`
+	IcgBas1     *int     `json:"icgBas1"`
+	IcgTop1     *int     `json:"icgTop1"`
+	IcgInt1     string   `json:"icgInt1"`
+	IcgType1    string   `json:"icgType1"`
+	IcgBas2     *int     `json:"icgBas2"`
+	IcgTop2     *int     `json:"icgTop2"`
+	IcgInt2     string   `json:"icgInt2"`
+	IcgType2    string   `json:"icgType2"`
+	TbBas1      *int     `json:"tbBas1"`
+	TbTop1      *int     `json:"tbTop1"`
+	TbInt1      string   `json:"tbInt1"`
+	TbType1     string   `json:"tbType1"`
+	TbFreq1     string   `json:"tbFreq1"`
+	TbBas2      *int     `json:"tbBas2"`
+	TbTop2      *int     `json:"tbTop2"`
+	TbInt2      string   `json:"tbInt2"`
+	TbType2     string   `json:"tbType2"`
+	TbFreq2     string   `json:"tbFreq2"`
+	VertGust    *int     `json:"vertGust"`
+	BrkAction   string   `json:"brkAction"`
+	PirepType   string   `json:"pirepType"`
+	RawOb       string   `json:"rawOb"`
+	Clouds      []struct {
+		Cover string `json:"cover"`
+		Base  *int   `json:"base"`
+		Top   *int   `json:"top"`
+	} `json:"clouds"`
+}
+
+// PIREPCondition is one decoded /TB or /IC group
+type PIREPCondition struct {
+	Intensity string `json:"intensity"` // NEG, LGT, MOD, SEV, LGT-MOD...
+	Type      string `json:"type"`      // RIME, CLR, MXD, CHOP, CAT...
+	Frequency string `json:"frequency"` // OCNL, CONS, INTMT
+	Base      *Feet  `json:"base"`
+	Top       *Feet  `json:"top"`
+}
+
+// Reported is true for anything other than an absent or negative report
+func (c PIREPCondition) Reported() bool {
+	return c.Intensity != "" && c.Intensity != "NEG"
+}
+
+// main internal struct
+type PIREP struct {
+	Raw          string           `json:"raw"`
+	Urgent       bool             `json:"urgent"`
+	Epoch        int64            `json:"epoch"`
+	Lat          float64          `json:"lat"`
+	Lon          float64          `json:"lon"`
+	DistanceNM   float64          `json:"distanceNM"`
+	Location     string           `json:"location"`     // /OV
+	Time         string           `json:"time"`         // /TM, hhmm zulu
+	Altitude     *Feet            `json:"altitude"`     // /FL
+	AircraftType string           `json:"aircraftType"` // /TP
+	Sky          string           `json:"sky"`          // /SK
+	Weather      string           `json:"weather"`      // /WX
+	Temp         *int             `json:"temp"`         // /TA, celsius
+	Wind         *WindData        `json:"wind"`         // /WV
+	Turbulence   []PIREPCondition `json:"turbulence"`   // /TB
+	Icing        []PIREPCondition `json:"icing"`        // /IC
+	Remarks      string           `json:"remarks"`      // /RM
+}
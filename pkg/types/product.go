@@ -0,0 +1,20 @@
+package types
+
+// product keys for Airport.Products and the intervals config
+const (
+	ProductMETAR   = "metar"
+	ProductTAF     = "taf"
+	ProductAFD     = "afd"
+	ProductStation = "station" // CWA lookup and station location
+	ProductPIREP   = "pirep"
+	ProductHazards = "hazards"
+	ProductAloft   = "aloft"
+)
+
+// ProductState tracks one product's refresh cycle
+type ProductState struct {
+	CheckedAt int64  `json:"checkedAt"` // last attempt, epoch seconds
+	FetchedAt int64  `json:"fetchedAt"` // last success, epoch seconds
+	Expires   int64  `json:"expires"`   // next refresh due, epoch seconds
+	LastError string `json:"lastError,omitempty"`
+}
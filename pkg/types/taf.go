@@ -0,0 +1,26 @@
+package types
+
+// TAF is a terminal forecast decoded from its raw text
+type TAF struct {
+	Station   string     `json:"station"`
+	Raw       string     `json:"raw"`
+	Amended   bool       `json:"amended,omitempty"`
+	Corrected bool       `json:"corrected,omitempty"`
+	Issued    int64      `json:"issued"`    // epoch seconds
+	ValidFrom int64      `json:"validFrom"` // epoch seconds
+	ValidTo   int64      `json:"validTo"`   // epoch seconds
+	Groups    []TAFGroup `json:"groups"`
+}
+
+// TAFGroup is the base forecast or one change group after it
+type TAFGroup struct {
+	Change     string      `json:"change"` // BASE, FM, BECMG, TEMPO, PROB30, PROB40, PROB30 TEMPO...
+	From       int64       `json:"from"`   // epoch seconds
+	To         int64       `json:"to"`     // epoch seconds
+	Wind       *WindData   `json:"wind,omitempty"`
+	Visibility Mi          `json:"visibility,omitempty"` // 99 for P6SM
+	WxString   string      `json:"wxString,omitempty"`
+	Clouds     []CloudData `json:"clouds,omitempty"`
+	WindShear  string      `json:"windShear,omitempty"` // WS group, e.g. "WS020/24040KT"
+	Raw        string      `json:"raw"`
+}